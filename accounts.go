@@ -0,0 +1,285 @@
+// Copyright 2026 Stigian Consulting - reference license in top level of project
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+const roleSessionName = "lsvpc"
+
+// roleARNs collects the roles given through -role-arn and -accounts-file.
+// The file holds one role arn per line, blank lines and lines starting with
+// # are skipped.
+func roleARNs() ([]string, error) {
+	roles := append([]string{}, Config.roleARNs...)
+
+	if Config.accountsFile != "" {
+		file, err := os.Open(Config.accountsFile)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			roles = append(roles, line)
+		}
+
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, role := range roles {
+		if _, err := roleAccountID(role); err != nil {
+			return nil, err
+		}
+	}
+
+	return roles, nil
+}
+
+// roleAccounts returns the accounts reached through the given role arns
+func roleAccounts(roles []string) []*AccountData {
+	accounts := []*AccountData{}
+
+	for _, role := range roles {
+		accountID, _ := roleAccountID(role) // validated by roleARNs
+		accounts = append(accounts, &AccountData{
+			ID:      accountID,
+			RoleARN: role,
+			Regions: make(map[string]RegionData),
+		})
+	}
+
+	return accounts
+}
+
+// profileAccounts returns the accounts of the given shared config profiles
+func profileAccounts(profiles []string) []*AccountData {
+	accounts := []*AccountData{}
+
+	for _, profile := range profiles {
+		accounts = append(accounts, &AccountData{
+			Profile: profile,
+			Regions: make(map[string]RegionData),
+		})
+	}
+
+	return accounts
+}
+
+// callerAccount returns the account the credentials loaded with optFns belong to
+func callerAccount(ctx context.Context, optFns ...func(*config.LoadOptions) error) (string, error) {
+	cfg, err := loadConfig(ctx, optFns...)
+	if err != nil {
+		return "", err
+	}
+
+	identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+
+	return aws.ToString(identity.Account), nil
+}
+
+// roleAccountID returns the account a role arn belongs to
+func roleAccountID(role string) (string, error) {
+	parsed, err := arn.Parse(role)
+	if err != nil || parsed.Service != "iam" || !strings.HasPrefix(parsed.Resource, "role/") {
+		return "", fmt.Errorf("'%v' is not a valid role arn", role)
+	}
+
+	return parsed.AccountID, nil
+}
+
+// assumeRole returns the config load options that make every client built
+// from them act as the given role. The role is assumed up front, so an
+// account that cannot be accessed fails here instead of in every region.
+func assumeRole(ctx context.Context, role string) ([]func(*config.LoadOptions) error, error) {
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(
+		sts.NewFromConfig(cfg),
+		role,
+		func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = roleSessionName
+		},
+	))
+
+	if _, err := provider.Retrieve(ctx); err != nil {
+		return nil, err
+	}
+
+	return []func(*config.LoadOptions) error{config.WithCredentialsProvider(provider)}, nil
+}
+
+// accountRegions lists the regions to fetch in an account, following -a and -r
+func accountRegions(ctx context.Context, optFns ...func(*config.LoadOptions) error) ([]string, error) {
+	switch {
+	case Config.allRegions:
+		return getRegions(ctx, optFns...)
+	case Config.regionOverride != "":
+		return []string{Config.regionOverride}, nil
+	}
+
+	cfg, err := loadConfig(ctx, optFns...)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{cfg.Region}, nil
+}
+
+func getAccountData(ctx context.Context, accountData *AccountData, out chan *AccountData) {
+	defer close(out)
+
+	out <- getAccount(ctx, accountData)
+}
+
+// getAccount fetches the regions of an account into accountData. Accounts
+// without a role or profile are fetched with the current credentials.
+func getAccount(ctx context.Context, accountData *AccountData) *AccountData {
+	var optFns []func(*config.LoadOptions) error
+
+	switch {
+	case accountData.RoleARN != "":
+		var err error
+
+		optFns, err = assumeRole(ctx, accountData.RoleARN)
+		if err != nil {
+			accountData.Err = errors.New(describeError(err))
+
+			return accountData
+		}
+	case accountData.Profile != "":
+		optFns = []func(*config.LoadOptions) error{config.WithSharedConfigProfile(accountData.Profile)}
+
+		// Profiles may belong to different partitions, so each one without
+		// a region of its own defaults to the primary region of its partition
+		partition, err := detectPartition(ctx, optFns...)
+		if err != nil {
+			accountData.Err = fmt.Errorf("could not determine the AWS partition: %v", describeError(err))
+
+			return accountData
+		}
+
+		optFns = append(optFns, config.WithDefaultRegion(partitionRegions[partition]))
+
+		accountID, err := callerAccount(ctx, optFns...)
+		if err != nil {
+			accountData.Err = errors.New(describeError(err))
+
+			return accountData
+		}
+
+		accountData.ID = accountID
+	}
+
+	regions, err := accountRegions(ctx, optFns...)
+	if err != nil {
+		accountData.Err = fmt.Errorf("could not get regions: %v", describeError(err))
+
+		return accountData
+	}
+
+	accountData.Regions = fetchRegions(ctx, regions, optFns...)
+
+	return accountData
+}
+
+// accountsFailed reports whether any account or any region within one failed
+func accountsFailed(accounts []*AccountData) bool {
+	for _, accountData := range accounts {
+		if accountData.Err != nil || regionsFailed(accountData.Regions) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func printAccounts(accounts []*AccountData) {
+	if Config.jsonOutput {
+		printAccountsJSON(sortAccountData(accounts))
+
+		return
+	}
+
+	for _, accountData := range accounts {
+		fmt.Printf("###%v###\n", accountTitle(accountData))
+
+		if accountData.Err != nil {
+			printAccountFailure(accountData.Err.Error())
+
+			continue
+		}
+
+		printRegions(accountData.Regions)
+	}
+}
+
+// accountTitle names an account by its id and name, prefixed with the
+// profile it was reached through if any.
+func accountTitle(accountData *AccountData) string {
+	title := accountData.ID + formatName(accountData.Name)
+
+	switch {
+	case accountData.Profile == "":
+		return title
+	case title == "":
+		return accountData.Profile
+	}
+
+	return accountData.Profile + ": " + title
+}
+
+func doAccounts(ctx context.Context, targets []*AccountData) int {
+	accounts := []*AccountData{}
+	channels := []chan *AccountData{}
+
+	for _, target := range targets {
+		out := make(chan *AccountData)
+		channels = append(channels, out)
+
+		go getAccountData(ctx, target, out)
+	}
+
+	for _, out := range channels {
+		accounts = append(accounts, <-out)
+	}
+
+	saveAccounts(accounts)
+
+	if Config.diffAgainst != "" {
+		return diffAgainst(accounts)
+	}
+
+	printAccounts(accounts)
+
+	if accountsFailed(accounts) {
+		return 1
+	}
+
+	return 0
+}
@@ -1,283 +1,390 @@
 // Copyright 2024 Stigian Consulting - reference license in top level of project
 
 // package awsfetch is a self-contained simple module to obtain all of the data
-// needed by lsvpc in a parallel fashion. This is achieved through the use of
-// unique channels that are defined for each unique sdk function queried.
+// needed by lsvpc in a parallel fashion. Every resource type is registered
+// once, as a Resource handle in requests.go, and GetAll runs the selected
+// fetchers concurrently, each storing its result into AWSFetch.
 package awsfetch
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
-	"github.com/aws/aws-sdk-go-v2/service/sts"
 )
 
-// AWSChan is a module within AWSFetch that contains the channels and services
-// necessary to query and return data to the main AWSFetch struct.
-// This is implemented using channels primarily as a test of the use of
-// channels, but also to make this slightly easier to extend than a
-// series of functions bounded by a wait group.
-type AWSChan struct {
-	Identity           chan GetIdentityOutput
-	Vpcs               chan GetVpcsOutput
-	Subnets            chan GetSubnetsOutput
-	Instances          chan GetInstancesOutput
-	InstanceStatuses   chan GetInstanceStatusOutput
-	Volumes            chan GetVolumesOutput
-	NatGateways        chan GetNatGatewaysOutput
-	RouteTables        chan GetRouteTablesOutput
-	InternetGateways   chan GetInternetGatewaysOutput
-	EOInternetGateways chan GetEgressOnlyInternetGatewaysOutput
-	VPNGateways        chan GetVPNGatewaysOutput
-	TransiGateways     chan GetTransitGatewaysOutput
-	PeeringConnections chan GetPeeringConnectionsOutput
-	NetworkInterfaces  chan GetNetworkInterfacesOutput
-	SecurityGroups     chan GetSecurityGroupsOutput
-	VPCEndpoints       chan GetVPCEndpointsOutput
-	svc                *ec2.Client
-	sts                *sts.Client
-}
+// registry holds every resource awsfetch knows how to retrieve, in the order
+// they were registered. Resources register themselves in requests.go, next to
+// the request function that retrieves them. Client only needs extending
+// when a new resource uses an sdk call awsfetch does not make yet.
+var registry = []Fetcher{}
 
-// AWSFetch is the primary struct used for obtaining the the data retrieved
-// from the sdk functions. Each of the Output members are simply the sdk return
-// types paired with an error value.
-type AWSFetch struct {
-	Identity           GetIdentityOutput
-	Vpcs               GetVpcsOutput
-	Subnets            GetSubnetsOutput
-	Instances          GetInstancesOutput
-	InstanceStatuses   GetInstanceStatusOutput
-	Volumes            GetVolumesOutput
-	NatGateways        GetNatGatewaysOutput
-	RouteTables        GetRouteTablesOutput
-	InternetGateways   GetInternetGatewaysOutput
-	EOInternetGateways GetEgressOnlyInternetGatewaysOutput
-	VPNGateways        GetVPNGatewaysOutput
-	TransiGateways     GetTransitGatewaysOutput
-	PeeringConnections GetPeeringConnectionsOutput
-	NetworkInterfaces  GetNetworkInterfacesOutput
-	c                  AWSChan // located here to avoid lint alignment complaints
-	SecurityGroups     GetSecurityGroupsOutput
-	VPCEndpoints       GetVPCEndpointsOutput
+// Resource is a handle on a registered resource type. Its Name selects it
+// through WithResources and labels its errors, From reads its result.
+type Resource[T any] struct {
+	Name string
 }
 
-type GetIdentityOutput struct {
-	Identity *sts.GetCallerIdentityOutput
-	Err      error
-}
+// From returns the result of the resource held by f, empty if it was not fetched.
+func (r Resource[T]) From(f *AWSFetch) Result[T] {
+	if res, ok := f.results[r.Name].(*Result[T]); ok {
+		return *res
+	}
 
-type GetVpcsOutput struct {
-	Err  error
-	Vpcs []types.Vpc
+	return Result[T]{}
 }
 
-type GetSubnetsOutput struct {
-	Err     error
-	Subnets []types.Subnet
+// register adds a resource retrieved by the given request function to the
+// registry. The request runs once the resources named in after have been
+// fetched, if they were selected, so it can read their results.
+func register[T any](
+	name string,
+	request func(*AWSFetch, context.Context) (T, error),
+	after ...string,
+) Resource[T] {
+	registry = append(registry, Fetcher{
+		Name: name,
+		deps: after,
+		empty: func() result {
+			return &Result[T]{}
+		},
+		fetch: func(ctx context.Context, f *AWSFetch) {
+			res, ok := f.results[name].(*Result[T])
+			if !ok {
+				return // unreachable, initResults gives every resource a result
+			}
+
+			cache := f.opts.Cache
+			if cache != nil {
+				var data T
+				if cache.Get(name, &data) {
+					*res = Result[T]{Data: data}
+
+					return
+				}
+			}
+
+			data, err := request(f, ctx)
+			*res = Result[T]{Data: data, Err: err}
+
+			if cache != nil && err == nil {
+				cache.Put(name, data)
+			}
+		},
+	})
+
+	return Resource[T]{Name: name}
 }
 
-type GetInstancesOutput struct {
-	Err       error
-	Instances []types.Reservation
+// Fetcher retrieves a single resource type into its result in AWSFetch.
+type Fetcher struct {
+	fetch func(ctx context.Context, f *AWSFetch)
+	empty func() result
+	Name  string
+	// deps names the fetchers whose results this one reads, GetAll runs it
+	// once those of them that were selected have finished
+	deps []string
 }
 
-type GetInstanceStatusOutput struct {
-	Err              error
-	InstanceStatuses []types.InstanceStatus
-}
+// Resources lists the names of every registered fetcher.
+func Resources() []string {
+	names := []string{}
+	for _, fetcher := range registry {
+		names = append(names, fetcher.Name)
+	}
 
-type GetVolumesOutput struct {
-	Err     error
-	Volumes []types.Volume
+	return names
 }
 
-type GetNatGatewaysOutput struct {
-	Err         error
-	NatGateways []types.NatGateway
+// result is the part of a Result that does not depend on its data type
+type result interface {
+	err() error
 }
 
-type GetRouteTablesOutput struct {
-	Err         error
-	RouteTables []types.RouteTable
+// Result is the data returned by a fetcher paired with an error value. It
+// encodes to json as just its data, errors are not retained.
+type Result[T any] struct {
+	Data T
+	Err  error
 }
 
-type GetInternetGatewaysOutput struct {
-	Err              error
-	InternetGateways []types.InternetGateway
+func (r *Result[T]) err() error {
+	return r.Err
 }
 
-type GetEgressOnlyInternetGatewaysOutput struct {
-	Err                error
-	EOInternetGateways []types.EgressOnlyInternetGateway
+func (r Result[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.Data)
 }
 
-type GetVPNGatewaysOutput struct {
-	Err         error
-	VPNGateways []types.VpnGateway
+func (r *Result[T]) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &r.Data)
 }
 
-type GetTransitGatewaysOutput struct {
-	Err             error
-	TransitGateways []types.TransitGatewayVpcAttachment
+// AWSFetch is the primary struct used for obtaining the the data retrieved
+// from the sdk functions. It holds a Result for every registered resource,
+// read through the resource's From, results of fetchers that were not run
+// are left empty. The results can be round-tripped through encoding/json,
+// as an object keyed by resource name.
+type AWSFetch struct {
+	svc     Client
+	results map[string]result
+	opts    Options
 }
 
-type GetPeeringConnectionsOutput struct {
-	Err                error
-	PeeringConnections []types.VpcPeeringConnection
-}
+// initResults gives every registered resource an empty result to be filled in
+func (f *AWSFetch) initResults() {
+	if f.results != nil {
+		return
+	}
 
-type GetNetworkInterfacesOutput struct {
-	Err               error
-	NetworkInterfaces []types.NetworkInterface
+	f.results = make(map[string]result)
+	for _, fetcher := range registry {
+		f.results[fetcher.Name] = fetcher.empty()
+	}
 }
 
-type GetSecurityGroupsOutput struct {
-	Err            error
-	SecurityGroups []types.SecurityGroup
+func (f AWSFetch) MarshalJSON() ([]byte, error) {
+	f.initResults()
+
+	buf := bytes.Buffer{}
+	buf.WriteByte('{')
+
+	for i, fetcher := range registry {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		name, _ := json.Marshal(fetcher.Name)
+		data, err := json.Marshal(f.results[fetcher.Name])
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(data)
+	}
+
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
 }
 
-type GetVPCEndpointsOutput struct {
-	Err          error
-	VPCEndpoints []types.VpcEndpoint
+func (f *AWSFetch) UnmarshalJSON(data []byte) error {
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	f.initResults()
+
+	for name, value := range raw {
+		res, ok := f.results[name]
+		if !ok {
+			continue // written by a version knowing other resources
+		}
+
+		if err := json.Unmarshal(value, res); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-// New initializes AWS Fetch and its internal AWSChan structs.
-// channels need to be explicitly allocated with make().
-func New(cfg aws.Config) AWSFetch {
-	f := AWSFetch{}
-	f.c = AWSChan{}
-	f.c.sts = sts.NewFromConfig(cfg)
-	f.c.svc = ec2.NewFromConfig(cfg)
-	f.c.Identity = make(chan GetIdentityOutput)
-	f.c.Vpcs = make(chan GetVpcsOutput)
-	f.c.Subnets = make(chan GetSubnetsOutput)
-	f.c.Instances = make(chan GetInstancesOutput)
-	f.c.InstanceStatuses = make(chan GetInstanceStatusOutput)
-	f.c.Volumes = make(chan GetVolumesOutput)
-	f.c.NatGateways = make(chan GetNatGatewaysOutput)
-	f.c.RouteTables = make(chan GetRouteTablesOutput)
-	f.c.InternetGateways = make(chan GetInternetGatewaysOutput)
-	f.c.EOInternetGateways = make(chan GetEgressOnlyInternetGatewaysOutput)
-	f.c.VPNGateways = make(chan GetVPNGatewaysOutput)
-	f.c.TransiGateways = make(chan GetTransitGatewaysOutput)
-	f.c.PeeringConnections = make(chan GetPeeringConnectionsOutput)
-	f.c.NetworkInterfaces = make(chan GetNetworkInterfacesOutput)
-	f.c.SecurityGroups = make(chan GetSecurityGroupsOutput)
-	f.c.VPCEndpoints = make(chan GetVPCEndpointsOutput)
+// PrefixList is a managed prefix list along with its entries, which have to
+// be requested separately for each list.
+type PrefixList struct {
+	types.ManagedPrefixList
+	Entries []types.PrefixListEntry
+}
 
-	return f
+// Options narrows down what is requested from AWS. They are set through the
+// With* functions passed to New.
+type Options struct {
+	// VpcIDs limits results to the given vpcs. Filtering happens server side
+	// for every call that supports a vpc filter, the rest return everything.
+	VpcIDs []string
+	// Tags limits instances to those carrying every tag key, with a value
+	// matching any of the given values. Values may contain the ec2 filter
+	// wildcards * and ?.
+	Tags map[string][]string
+	// Resources selects the fetchers GetAll runs by name, all of them if empty.
+	Resources []string
+	// Cache, if set, is consulted before each fetcher makes its calls, and
+	// stores the results of the calls that succeeded.
+	Cache Cache
 }
 
-// GetAll spawns goroutines to concurrently request the aws sdk
-// for all of the information relevant to a vpc. each function
-// returns its results into their specific channel, and those
-// channels are then read into the AWSFetch struct members.
-func (f *AWSFetch) GetAll(ctx context.Context) (*AWSFetch, error) {
-	go f.c.GetIdentity(ctx)
-	go f.c.GetVpcs(ctx)
-	go f.c.GetSubnets(ctx)
-	go f.c.GetInstances(ctx)
-	go f.c.GetInstanceStatuses(ctx)
-	go f.c.GetNatGatways(ctx)
-	go f.c.GetRouteTables(ctx)
-	go f.c.GetInternetGateways(ctx)
-	go f.c.GetEgressOnlyInternetGateways(ctx)
-	go f.c.GetVPNGateways(ctx)
-	go f.c.GetTransitGatewayVpcAttachments(ctx)
-	go f.c.GetVpcPeeringConnections(ctx)
-	go f.c.GetNetworkInterfaces(ctx)
-	go f.c.GetSecurityGroups(ctx)
-	go f.c.GetVpcEndpoints(ctx)
-	go f.c.GetVolumes(ctx)
-
-	f.Identity = <-f.c.Identity
-	f.Vpcs = <-f.c.Vpcs
-	f.Subnets = <-f.c.Subnets
-	f.Instances = <-f.c.Instances
-	f.InstanceStatuses = <-f.c.InstanceStatuses
-	f.Volumes = <-f.c.Volumes
-	f.NatGateways = <-f.c.NatGateways
-	f.RouteTables = <-f.c.RouteTables
-	f.InternetGateways = <-f.c.InternetGateways
-	f.EOInternetGateways = <-f.c.EOInternetGateways
-	f.VPNGateways = <-f.c.VPNGateways
-	f.TransiGateways = <-f.c.TransiGateways
-	f.PeeringConnections = <-f.c.PeeringConnections
-	f.NetworkInterfaces = <-f.c.NetworkInterfaces
-	f.SecurityGroups = <-f.c.SecurityGroups
-	f.VPCEndpoints = <-f.c.VPCEndpoints
-
-	err := f.Error()
-
-	return f, err
+// Cache keeps fetched data between runs. Get decodes the data cached for the
+// named resource into v, and reports whether there was any still fresh
+// enough to use. The cache is expected to be scoped to a single account,
+// region and set of Options.
+type Cache interface {
+	Get(resource string, v any) bool
+	Put(resource string, v any)
 }
 
-func (f *AWSFetch) Error() error {
-	if f.Identity.Err != nil {
-		return f.Identity.Err
+// WithVpcIDs limits fetching to the given vpcs.
+func WithVpcIDs(ids ...string) func(*Options) {
+	return func(o *Options) {
+		o.VpcIDs = append(o.VpcIDs, ids...)
 	}
+}
 
-	if f.Vpcs.Err != nil {
-		return f.Vpcs.Err
+// WithTags limits instances to those matching the given tags.
+func WithTags(tags map[string][]string) func(*Options) {
+	return func(o *Options) {
+		o.Tags = tags
 	}
+}
 
-	if f.Subnets.Err != nil {
-		return f.Subnets.Err
+// WithResources limits GetAll to the named fetchers.
+func WithResources(names ...string) func(*Options) {
+	return func(o *Options) {
+		o.Resources = append(o.Resources, names...)
 	}
+}
 
-	if f.Instances.Err != nil {
-		return f.Instances.Err
+// WithCache serves and stores fetched data through the given cache.
+func WithCache(cache Cache) func(*Options) {
+	return func(o *Options) {
+		o.Cache = cache
 	}
+}
 
-	if f.InstanceStatuses.Err != nil {
-		return f.InstanceStatuses.Err
-	}
+// New initializes AWSFetch around the given client. Use NewClient to query
+// live AWS.
+func New(client Client, optFns ...func(*Options)) AWSFetch {
+	f := AWSFetch{}
+	f.svc = client
+	f.initResults()
 
-	if f.Volumes.Err != nil {
-		return f.Volumes.Err
+	for _, fn := range optFns {
+		fn(&f.opts)
 	}
 
-	if f.NatGateways.Err != nil {
-		return f.NatGateways.Err
-	}
+	return f
+}
 
-	if f.RouteTables.Err != nil {
-		return f.RouteTables.Err
+// selected returns the fetchers chosen through Options.Resources.
+func (f *AWSFetch) selected() ([]Fetcher, error) {
+	if len(f.opts.Resources) == 0 {
+		return registry, nil
 	}
 
-	if f.InternetGateways.Err != nil {
-		return f.InternetGateways.Err
-	}
+	fetchers := []Fetcher{}
+	seen := make(map[string]bool)
 
-	if f.EOInternetGateways.Err != nil {
-		return f.EOInternetGateways.Err
-	}
+	for _, name := range f.opts.Resources {
+		if seen[name] {
+			continue // a resource named twice is only fetched once
+		}
 
-	if f.VPNGateways.Err != nil {
-		return f.VPNGateways.Err
+		seen[name] = true
+		found := false
+
+		for _, fetcher := range registry {
+			if fetcher.Name == name {
+				fetchers = append(fetchers, fetcher)
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("unknown resource %q", name)
+		}
 	}
 
-	if f.TransiGateways.Err != nil {
-		return f.TransiGateways.Err
+	return fetchers, nil
+}
+
+// GetAll concurrently runs every selected fetcher, each storing its result
+// into AWSFetch.
+// A failing call does not prevent the others from completing, the returned
+// error aggregates every failure and the successful results are kept.
+func (f *AWSFetch) GetAll(ctx context.Context) (*AWSFetch, error) {
+	fetchers, err := f.selected()
+	if err != nil {
+		return f, err
 	}
 
-	if f.PeeringConnections.Err != nil {
-		return f.PeeringConnections.Err
+	f.initResults()
+
+	wg := sync.WaitGroup{}
+
+	done := make(map[string]chan struct{})
+	for _, fetcher := range fetchers {
+		done[fetcher.Name] = make(chan struct{})
 	}
 
-	if f.NetworkInterfaces.Err != nil {
-		return f.NetworkInterfaces.Err
+	for _, fetcher := range fetchers {
+		wg.Add(1)
+
+		go func(fetcher Fetcher) {
+			defer wg.Done()
+			defer close(done[fetcher.Name])
+
+			for _, dep := range fetcher.deps {
+				if ch, ok := done[dep]; ok {
+					<-ch
+				}
+			}
+
+			fetcher.fetch(ctx, f)
+		}(fetcher)
 	}
 
-	if f.SecurityGroups.Err != nil {
-		return f.SecurityGroups.Err
+	wg.Wait()
+
+	return f, f.Error()
+}
+
+// ResourceError is a failed sdk call, labeled with the resource it was made for.
+type ResourceError struct {
+	Err      error
+	Resource string
+}
+
+func (e *ResourceError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Resource, e.Err)
+}
+
+func (e *ResourceError) Unwrap() error {
+	return e.Err
+}
+
+// Errors returns every call that failed during GetAll. Results from the calls
+// that succeeded remain usable.
+func (f *AWSFetch) Errors() []*ResourceError {
+	errs := []*ResourceError{}
+
+	for _, fetcher := range registry {
+		res, ok := f.results[fetcher.Name]
+		if !ok {
+			continue
+		}
+
+		if err := res.err(); err != nil {
+			errs = append(errs, &ResourceError{Resource: fetcher.Name, Err: err})
+		}
 	}
 
-	if f.VPCEndpoints.Err != nil {
-		return f.VPCEndpoints.Err
+	return errs
+}
+
+// Error aggregates every failed call into a single error, nil if all succeeded.
+func (f *AWSFetch) Error() error {
+	errs := []error{}
+	for _, err := range f.Errors() {
+		errs = append(errs, err)
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
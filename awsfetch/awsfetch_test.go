@@ -0,0 +1,203 @@
+// Copyright 2026 Stigian Consulting - reference license in top level of project
+package awsfetch_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"github.com/stigian/lsvpc/awsfetch"
+)
+
+// fakeClient answers the calls the tests below need from canned data and
+// counts them. Calls it does not implement fall through to the nil Client
+// and panic, so a fetcher that should not have run fails the test.
+type fakeClient struct {
+	awsfetch.Client
+
+	mu    sync.Mutex
+	calls map[string]int
+	// prefixListFilter is the prefix-list-id filter DescribeManagedPrefixLists received
+	prefixListFilter []string
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{calls: make(map[string]int)}
+}
+
+func (c *fakeClient) called(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls[name]++
+}
+
+func (c *fakeClient) count(name string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.calls[name]
+}
+
+func (c *fakeClient) DescribeVpcs(context.Context, *ec2.DescribeVpcsInput, ...func(*ec2.Options)) (*ec2.DescribeVpcsOutput, error) {
+	c.called("DescribeVpcs")
+
+	return &ec2.DescribeVpcsOutput{Vpcs: []types.Vpc{{VpcId: aws.String("vpc-1")}}}, nil
+}
+
+func (c *fakeClient) DescribeSubnets(context.Context, *ec2.DescribeSubnetsInput, ...func(*ec2.Options)) (*ec2.DescribeSubnetsOutput, error) {
+	c.called("DescribeSubnets")
+
+	return nil, errors.New("UnauthorizedOperation")
+}
+
+func (c *fakeClient) DescribeRouteTables(context.Context, *ec2.DescribeRouteTablesInput, ...func(*ec2.Options)) (*ec2.DescribeRouteTablesOutput, error) {
+	c.called("DescribeRouteTables")
+
+	// Slow enough that prefix lists would miss this reference if they didn't wait
+	time.Sleep(50 * time.Millisecond)
+
+	return &ec2.DescribeRouteTablesOutput{RouteTables: []types.RouteTable{{
+		RouteTableId: aws.String("rtb-1"),
+		Routes:       []types.Route{{DestinationPrefixListId: aws.String("pl-route")}},
+	}}}, nil
+}
+
+func (c *fakeClient) DescribeSecurityGroups(context.Context, *ec2.DescribeSecurityGroupsInput, ...func(*ec2.Options)) (*ec2.DescribeSecurityGroupsOutput, error) {
+	c.called("DescribeSecurityGroups")
+
+	return &ec2.DescribeSecurityGroupsOutput{SecurityGroups: []types.SecurityGroup{{
+		GroupId: aws.String("sg-1"),
+		IpPermissions: []types.IpPermission{{
+			PrefixListIds: []types.PrefixListId{{PrefixListId: aws.String("pl-group")}},
+		}},
+	}}}, nil
+}
+
+func (c *fakeClient) DescribeManagedPrefixLists(
+	_ context.Context, params *ec2.DescribeManagedPrefixListsInput, _ ...func(*ec2.Options),
+) (*ec2.DescribeManagedPrefixListsOutput, error) {
+	c.called("DescribeManagedPrefixLists")
+
+	c.mu.Lock()
+	for _, filter := range params.Filters {
+		c.prefixListFilter = append(c.prefixListFilter, filter.Values...)
+	}
+	c.mu.Unlock()
+
+	return &ec2.DescribeManagedPrefixListsOutput{PrefixLists: []types.ManagedPrefixList{
+		{PrefixListId: aws.String("pl-route")},
+		{PrefixListId: aws.String("pl-group")},
+	}}, nil
+}
+
+func (c *fakeClient) GetManagedPrefixListEntries(
+	_ context.Context, params *ec2.GetManagedPrefixListEntriesInput, _ ...func(*ec2.Options),
+) (*ec2.GetManagedPrefixListEntriesOutput, error) {
+	c.called("GetManagedPrefixListEntries")
+
+	if aws.ToString(params.PrefixListId) == "pl-group" {
+		return nil, errors.New("AccessDenied")
+	}
+
+	return &ec2.GetManagedPrefixListEntriesOutput{Entries: []types.PrefixListEntry{{Cidr: aws.String("0.0.0.0/0")}}}, nil
+}
+
+func TestGetAllKeepsResultsOfCallsThatSucceeded(t *testing.T) {
+	client := newFakeClient()
+	fetch := awsfetch.New(client, awsfetch.WithResources(awsfetch.Vpcs.Name, awsfetch.Subnets.Name))
+
+	received, err := fetch.GetAll(context.Background())
+	if err == nil {
+		t.Fatal("expected the failed subnets call to be reported")
+	}
+
+	if vpcs := awsfetch.Vpcs.From(received).Data; len(vpcs) != 1 || aws.ToString(vpcs[0].VpcId) != "vpc-1" {
+		t.Errorf("vpcs = %v, want vpc-1", vpcs)
+	}
+
+	errs := received.Errors()
+	if len(errs) != 1 || errs[0].Resource != awsfetch.Subnets.Name {
+		t.Fatalf("errors = %v, want only %v", errs, awsfetch.Subnets.Name)
+	}
+}
+
+func TestGetAllRunsPrefixListsAfterTheirReferences(t *testing.T) {
+	client := newFakeClient()
+	fetch := awsfetch.New(client, awsfetch.WithResources(
+		awsfetch.PrefixLists.Name, awsfetch.RouteTables.Name, awsfetch.SecurityGroups.Name,
+	))
+
+	received, err := fetch.GetAll(context.Background())
+	if err == nil {
+		t.Fatal("expected the failed entries of pl-group to be reported")
+	}
+
+	filter := append([]string{}, client.prefixListFilter...)
+	sort.Strings(filter)
+
+	if len(filter) != 2 || filter[0] != "pl-group" || filter[1] != "pl-route" {
+		t.Errorf("prefix lists requested = %v, want the route's and the group's", filter)
+	}
+
+	entries := make(map[string][]types.PrefixListEntry)
+	for _, list := range awsfetch.PrefixLists.From(received).Data {
+		entries[aws.ToString(list.PrefixListId)] = list.Entries
+	}
+
+	if len(entries["pl-route"]) != 1 {
+		t.Errorf("entries of pl-route = %v, want one", entries["pl-route"])
+	}
+
+	if list, ok := entries["pl-group"]; !ok || list != nil {
+		t.Errorf("entries of pl-group = %v, want the list kept with nil entries", list)
+	}
+
+	errs := received.Errors()
+	if len(errs) != 1 || errs[0].Resource != awsfetch.PrefixLists.Name {
+		t.Fatalf("errors = %v, want only %v", errs, awsfetch.PrefixLists.Name)
+	}
+}
+
+func TestGetAllOnlyRunsSelectedResources(t *testing.T) {
+	client := newFakeClient()
+	fetch := awsfetch.New(client, awsfetch.WithResources(awsfetch.Vpcs.Name, awsfetch.Vpcs.Name))
+
+	received, err := fetch.GetAll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := client.count("DescribeVpcs"); n != 1 {
+		t.Errorf("DescribeVpcs called %v times, want once", n)
+	}
+
+	if subnets := awsfetch.Subnets.From(received).Data; subnets != nil {
+		t.Errorf("subnets = %v, want nothing fetched", subnets)
+	}
+
+	// Prefix lists depend on route tables, selecting them alone must not wait forever
+	fetch = awsfetch.New(client, awsfetch.WithResources(awsfetch.PrefixLists.Name))
+	if _, err := fetch.GetAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := client.count("DescribeManagedPrefixLists"); n != 0 {
+		t.Errorf("DescribeManagedPrefixLists called %v times without any references", n)
+	}
+}
+
+func TestGetAllRejectsUnknownResources(t *testing.T) {
+	fetch := awsfetch.New(newFakeClient(), awsfetch.WithResources("nope"))
+
+	if _, err := fetch.GetAll(context.Background()); err == nil {
+		t.Fatal("expected an unknown resource to be rejected")
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2026 Stigian Consulting - reference license in top level of project
+package awsfetch
+
+import (
+	"context"
+
+	"github.com/aws/smithy-go/middleware"
+)
+
+// Limiter bounds the number of sdk requests in flight at once. A single
+// Limiter is meant to be shared by every client lsvpc builds, so that one
+// limit covers all regions and all resource fetchers together.
+type Limiter struct {
+	slots chan struct{}
+}
+
+// NewLimiter allows up to limit concurrent requests.
+func NewLimiter(limit int) *Limiter {
+	return &Limiter{
+		slots: make(chan struct{}, limit),
+	}
+}
+
+// Middleware installs the limiter on an sdk client's middleware stack. It
+// is meant for aws.Config.APIOptions. It sits after the retry middleware, so
+// each attempt holds a slot while backoff delays between attempts do not.
+func (l *Limiter) Middleware(stack *middleware.Stack) error {
+	return stack.Finalize.Add(
+		middleware.FinalizeMiddlewareFunc("lsvpcConcurrencyLimit", l.handleFinalize),
+		middleware.After,
+	)
+}
+
+func (l *Limiter) handleFinalize(
+	ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler,
+) (middleware.FinalizeOutput, middleware.Metadata, error) {
+	select {
+	case l.slots <- struct{}{}:
+	case <-ctx.Done():
+		return middleware.FinalizeOutput{}, middleware.Metadata{}, ctx.Err()
+	}
+
+	defer func() { <-l.slots }()
+
+	return next.HandleFinalize(ctx, in)
+}
@@ -3,305 +3,347 @@ package awsfetch
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 )
 
-func (c *AWSChan) GetIdentity(ctx context.Context) {
-	res, err := c.sts.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
-
-	c.Identity <- GetIdentityOutput{
-		Identity: res,
-		Err:      err,
-	}
+// pager is satisfied by every ec2 paginator, P being the output type of the call.
+type pager[P any] interface {
+	HasMorePages() bool
+	NextPage(ctx context.Context, optFns ...func(*ec2.Options)) (P, error)
 }
 
-func (c *AWSChan) GetVpcs(ctx context.Context) {
-	vpcs := []types.Vpc{}
-	paginator := ec2.NewDescribeVpcsPaginator(c.svc, &ec2.DescribeVpcsInput{})
-	
-	var err error
-	for paginator.HasMorePages() {
-		page, pageErr := paginator.NextPage(ctx)
-		if pageErr != nil {
-			err = pageErr
-			break
+// paginate collects the items of every page. If a page fails, the items from
+// the pages before it are returned along with the error.
+func paginate[P, T any](ctx context.Context, p pager[P], items func(P) []T) ([]T, error) {
+	all := []T{}
+
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return all, err
 		}
-		vpcs = append(vpcs, page.Vpcs...)
-	}
 
-	c.Vpcs <- GetVpcsOutput{
-		Err:  err,
-		Vpcs: vpcs,
+		all = append(all, items(page)...)
 	}
+
+	return all, nil
 }
 
-func (c *AWSChan) GetSubnets(ctx context.Context) {
-	subnets := []types.Subnet{}
-	paginator := ec2.NewDescribeSubnetsPaginator(c.svc, &ec2.DescribeSubnetsInput{})
-	
-	var err error
-	for paginator.HasMorePages() {
-		page, pageErr := paginator.NextPage(ctx)
-		if pageErr != nil {
-			err = pageErr
-			break
-		}
-		subnets = append(subnets, page.Subnets...)
+// vpcFilter returns a filter on the requested vpcs under the given filter
+// name, which differs between api calls. No filter is returned if no vpcs
+// were requested.
+func (f *AWSFetch) vpcFilter(name string) []types.Filter {
+	if len(f.opts.VpcIDs) == 0 {
+		return nil
 	}
 
-	c.Subnets <- GetSubnetsOutput{
-		Subnets: subnets,
-		Err:     err,
-	}
+	return []types.Filter{{
+		Name:   aws.String(name),
+		Values: f.opts.VpcIDs,
+	}}
 }
 
-func (c *AWSChan) GetInstances(ctx context.Context) {
-	instances := []types.Reservation{}
-	paginator := ec2.NewDescribeInstancesPaginator(c.svc, &ec2.DescribeInstancesInput{})
-	
-	var err error
-	for paginator.HasMorePages() {
-		page, pageErr := paginator.NextPage(ctx)
-		if pageErr != nil {
-			err = pageErr
-			break
-		}
-		instances = append(instances, page.Reservations...)
+// tagFilters returns a filter for each requested tag key. These are only
+// applied to the instance call. Vpcs and subnets are shown whenever something
+// inside them matches, so they have to be filtered after the fact, as do
+// network interfaces, the same call also providing the interfaces of nat
+// gateways and endpoints.
+func (f *AWSFetch) tagFilters() []types.Filter {
+	filters := []types.Filter{}
+
+	for key, values := range f.opts.Tags {
+		filters = append(filters, types.Filter{
+			Name:   aws.String("tag:" + key),
+			Values: values,
+		})
 	}
 
-	c.Instances <- GetInstancesOutput{
-		Instances: instances,
-		Err:       err,
-	}
+	return filters
 }
 
-func (c *AWSChan) GetInstanceStatuses(ctx context.Context) {
-	statuses := []types.InstanceStatus{}
-	paginator := ec2.NewDescribeInstanceStatusPaginator(c.svc, &ec2.DescribeInstanceStatusInput{})
-	
-	var err error
-	for paginator.HasMorePages() {
-		page, pageErr := paginator.NextPage(ctx)
-		if pageErr != nil {
-			err = pageErr
-			break
-		}
-		statuses = append(statuses, page.InstanceStatuses...)
-	}
+var Identity = register("identity", (*AWSFetch).getIdentity)
 
-	c.InstanceStatuses <- GetInstanceStatusOutput{
-		InstanceStatuses: statuses,
-		Err:              err,
-	}
+func (f *AWSFetch) getIdentity(ctx context.Context) (*sts.GetCallerIdentityOutput, error) {
+	return f.svc.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
 }
 
-func (c *AWSChan) GetNatGatways(ctx context.Context) {
-	natGateways := []types.NatGateway{}
-	paginator := ec2.NewDescribeNatGatewaysPaginator(c.svc, &ec2.DescribeNatGatewaysInput{})
-	
-	var err error
-	for paginator.HasMorePages() {
-		page, pageErr := paginator.NextPage(ctx)
-		if pageErr != nil {
-			err = pageErr
-			break
-		}
-		natGateways = append(natGateways, page.NatGateways...)
-	}
+var Vpcs = register("vpcs", (*AWSFetch).getVpcs)
 
-	c.NatGateways <- GetNatGatewaysOutput{
-		NatGateways: natGateways,
-		Err:         err,
-	}
+func (f *AWSFetch) getVpcs(ctx context.Context) ([]types.Vpc, error) {
+	paginator := ec2.NewDescribeVpcsPaginator(f.svc, &ec2.DescribeVpcsInput{
+		Filters: f.vpcFilter("vpc-id"),
+	})
+
+	return paginate(ctx, paginator, func(page *ec2.DescribeVpcsOutput) []types.Vpc {
+		return page.Vpcs
+	})
 }
 
-func (c *AWSChan) GetRouteTables(ctx context.Context) {
-	routeTables := []types.RouteTable{}
-	paginator := ec2.NewDescribeRouteTablesPaginator(c.svc, &ec2.DescribeRouteTablesInput{})
-	
-	var err error
-	for paginator.HasMorePages() {
-		page, pageErr := paginator.NextPage(ctx)
-		if pageErr != nil {
-			err = pageErr
-			break
-		}
-		routeTables = append(routeTables, page.RouteTables...)
-	}
+var Subnets = register("subnets", (*AWSFetch).getSubnets)
 
-	c.RouteTables <- GetRouteTablesOutput{
-		RouteTables: routeTables,
-		Err:         err,
-	}
+func (f *AWSFetch) getSubnets(ctx context.Context) ([]types.Subnet, error) {
+	paginator := ec2.NewDescribeSubnetsPaginator(f.svc, &ec2.DescribeSubnetsInput{
+		Filters: f.vpcFilter("vpc-id"),
+	})
+
+	return paginate(ctx, paginator, func(page *ec2.DescribeSubnetsOutput) []types.Subnet {
+		return page.Subnets
+	})
 }
 
-func (c *AWSChan) GetInternetGateways(ctx context.Context) {
-	internetGateways := []types.InternetGateway{}
-	paginator := ec2.NewDescribeInternetGatewaysPaginator(c.svc, &ec2.DescribeInternetGatewaysInput{})
-	
-	var err error
-	for paginator.HasMorePages() {
-		page, pageErr := paginator.NextPage(ctx)
-		if pageErr != nil {
-			err = pageErr
-			break
-		}
-		internetGateways = append(internetGateways, page.InternetGateways...)
-	}
+var Instances = register("instances", (*AWSFetch).getInstances)
 
-	c.InternetGateways <- GetInternetGatewaysOutput{
-		InternetGateways: internetGateways,
-		Err:              err,
-	}
+func (f *AWSFetch) getInstances(ctx context.Context) ([]types.Reservation, error) {
+	paginator := ec2.NewDescribeInstancesPaginator(f.svc, &ec2.DescribeInstancesInput{
+		Filters: append(f.vpcFilter("vpc-id"), f.tagFilters()...),
+	})
+
+	return paginate(ctx, paginator, func(page *ec2.DescribeInstancesOutput) []types.Reservation {
+		return page.Reservations
+	})
 }
 
-func (c *AWSChan) GetEgressOnlyInternetGateways(ctx context.Context) {
-	EOIGWs := []types.EgressOnlyInternetGateway{}
-	paginator := ec2.NewDescribeEgressOnlyInternetGatewaysPaginator(c.svc, &ec2.DescribeEgressOnlyInternetGatewaysInput{})
-	
-	var err error
-	for paginator.HasMorePages() {
-		page, pageErr := paginator.NextPage(ctx)
-		if pageErr != nil {
-			err = pageErr
-			break
-		}
-		EOIGWs = append(EOIGWs, page.EgressOnlyInternetGateways...)
-	}
+var InstanceStatuses = register("instanceStatuses", (*AWSFetch).getInstanceStatuses)
 
-	c.EOInternetGateways <- GetEgressOnlyInternetGatewaysOutput{
-		EOInternetGateways: EOIGWs,
-		Err:                err,
-	}
+func (f *AWSFetch) getInstanceStatuses(ctx context.Context) ([]types.InstanceStatus, error) {
+	paginator := ec2.NewDescribeInstanceStatusPaginator(f.svc, &ec2.DescribeInstanceStatusInput{})
+
+	return paginate(ctx, paginator, func(page *ec2.DescribeInstanceStatusOutput) []types.InstanceStatus {
+		return page.InstanceStatuses
+	})
 }
 
-func (c *AWSChan) GetVPNGateways(ctx context.Context) {
-	res, err := c.svc.DescribeVpnGateways(ctx, &ec2.DescribeVpnGatewaysInput{})
-	c.VPNGateways <- GetVPNGatewaysOutput{
-		VPNGateways: res.VpnGateways,
-		Err:         err,
-	}
+var Volumes = register("volumes", (*AWSFetch).getVolumes)
+
+func (f *AWSFetch) getVolumes(ctx context.Context) ([]types.Volume, error) {
+	paginator := ec2.NewDescribeVolumesPaginator(f.svc, &ec2.DescribeVolumesInput{})
+
+	return paginate(ctx, paginator, func(page *ec2.DescribeVolumesOutput) []types.Volume {
+		return page.Volumes
+	})
 }
 
-func (c *AWSChan) GetTransitGatewayVpcAttachments(ctx context.Context) {
-	TGWatt := []types.TransitGatewayVpcAttachment{}
-	paginator := ec2.NewDescribeTransitGatewayVpcAttachmentsPaginator(c.svc, &ec2.DescribeTransitGatewayVpcAttachmentsInput{})
-	
-	var err error
-	for paginator.HasMorePages() {
-		page, pageErr := paginator.NextPage(ctx)
-		if pageErr != nil {
-			err = pageErr
-			break
-		}
-		TGWatt = append(TGWatt, page.TransitGatewayVpcAttachments...)
-	}
+var NatGateways = register("natGateways", (*AWSFetch).getNatGateways)
 
-	c.TransiGateways <- GetTransitGatewaysOutput{
-		TransitGateways: TGWatt,
-		Err:             err,
-	}
+func (f *AWSFetch) getNatGateways(ctx context.Context) ([]types.NatGateway, error) {
+	paginator := ec2.NewDescribeNatGatewaysPaginator(f.svc, &ec2.DescribeNatGatewaysInput{
+		Filter: f.vpcFilter("vpc-id"),
+	})
+
+	return paginate(ctx, paginator, func(page *ec2.DescribeNatGatewaysOutput) []types.NatGateway {
+		return page.NatGateways
+	})
 }
 
-func (c *AWSChan) GetVpcPeeringConnections(ctx context.Context) {
-	peers := []types.VpcPeeringConnection{}
-	paginator := ec2.NewDescribeVpcPeeringConnectionsPaginator(c.svc, &ec2.DescribeVpcPeeringConnectionsInput{})
-	
-	var err error
-	for paginator.HasMorePages() {
-		page, pageErr := paginator.NextPage(ctx)
-		if pageErr != nil {
-			err = pageErr
-			break
-		}
-		peers = append(peers, page.VpcPeeringConnections...)
-	}
+var RouteTables = register("routeTables", (*AWSFetch).getRouteTables)
 
-	c.PeeringConnections <- GetPeeringConnectionsOutput{
-		PeeringConnections: peers,
-		Err:                err,
-	}
+func (f *AWSFetch) getRouteTables(ctx context.Context) ([]types.RouteTable, error) {
+	paginator := ec2.NewDescribeRouteTablesPaginator(f.svc, &ec2.DescribeRouteTablesInput{
+		Filters: f.vpcFilter("vpc-id"),
+	})
+
+	return paginate(ctx, paginator, func(page *ec2.DescribeRouteTablesOutput) []types.RouteTable {
+		return page.RouteTables
+	})
 }
 
-func (c *AWSChan) GetNetworkInterfaces(ctx context.Context) {
-	ifaces := []types.NetworkInterface{}
-	paginator := ec2.NewDescribeNetworkInterfacesPaginator(c.svc, &ec2.DescribeNetworkInterfacesInput{})
-	
-	var err error
-	for paginator.HasMorePages() {
-		page, pageErr := paginator.NextPage(ctx)
-		if pageErr != nil {
-			err = pageErr
-			break
-		}
-		ifaces = append(ifaces, page.NetworkInterfaces...)
+var InternetGateways = register("internetGateways", (*AWSFetch).getInternetGateways)
+
+func (f *AWSFetch) getInternetGateways(ctx context.Context) ([]types.InternetGateway, error) {
+	paginator := ec2.NewDescribeInternetGatewaysPaginator(f.svc, &ec2.DescribeInternetGatewaysInput{
+		Filters: f.vpcFilter("attachment.vpc-id"),
+	})
+
+	return paginate(ctx, paginator, func(page *ec2.DescribeInternetGatewaysOutput) []types.InternetGateway {
+		return page.InternetGateways
+	})
+}
+
+var EgressOnlyInternetGateways = register("egressOnlyInternetGateways", (*AWSFetch).getEgressOnlyInternetGateways)
+
+func (f *AWSFetch) getEgressOnlyInternetGateways(ctx context.Context) ([]types.EgressOnlyInternetGateway, error) {
+	paginator := ec2.NewDescribeEgressOnlyInternetGatewaysPaginator(f.svc, &ec2.DescribeEgressOnlyInternetGatewaysInput{})
+
+	return paginate(ctx, paginator, func(page *ec2.DescribeEgressOnlyInternetGatewaysOutput) []types.EgressOnlyInternetGateway {
+		return page.EgressOnlyInternetGateways
+	})
+}
+
+var VPNGateways = register("vpnGateways", (*AWSFetch).getVPNGateways)
+
+// getVPNGateways is not paginated, DescribeVpnGateways returns every gateway at once
+func (f *AWSFetch) getVPNGateways(ctx context.Context) ([]types.VpnGateway, error) {
+	res, err := f.svc.DescribeVpnGateways(ctx, &ec2.DescribeVpnGatewaysInput{
+		Filters: f.vpcFilter("attachment.vpc-id"),
+	})
+	if err != nil {
+		return []types.VpnGateway{}, err
 	}
 
-	c.NetworkInterfaces <- GetNetworkInterfacesOutput{
-		NetworkInterfaces: ifaces,
-		Err:               err,
+	return res.VpnGateways, nil
+}
+
+var TransitGatewayAttachments = register("transitGatewayAttachments", (*AWSFetch).getTransitGatewayVpcAttachments)
+
+func (f *AWSFetch) getTransitGatewayVpcAttachments(ctx context.Context) ([]types.TransitGatewayVpcAttachment, error) {
+	paginator := ec2.NewDescribeTransitGatewayVpcAttachmentsPaginator(f.svc, &ec2.DescribeTransitGatewayVpcAttachmentsInput{
+		Filters: f.vpcFilter("vpc-id"),
+	})
+
+	return paginate(ctx, paginator, func(page *ec2.DescribeTransitGatewayVpcAttachmentsOutput) []types.TransitGatewayVpcAttachment {
+		return page.TransitGatewayVpcAttachments
+	})
+}
+
+var PeeringConnections = register("peeringConnections", (*AWSFetch).getVpcPeeringConnections)
+
+func (f *AWSFetch) getVpcPeeringConnections(ctx context.Context) ([]types.VpcPeeringConnection, error) {
+	paginator := ec2.NewDescribeVpcPeeringConnectionsPaginator(f.svc, &ec2.DescribeVpcPeeringConnectionsInput{})
+
+	return paginate(ctx, paginator, func(page *ec2.DescribeVpcPeeringConnectionsOutput) []types.VpcPeeringConnection {
+		return page.VpcPeeringConnections
+	})
+}
+
+var NetworkInterfaces = register("networkInterfaces", (*AWSFetch).getNetworkInterfaces)
+
+func (f *AWSFetch) getNetworkInterfaces(ctx context.Context) ([]types.NetworkInterface, error) {
+	paginator := ec2.NewDescribeNetworkInterfacesPaginator(f.svc, &ec2.DescribeNetworkInterfacesInput{
+		Filters: f.vpcFilter("vpc-id"),
+	})
+
+	return paginate(ctx, paginator, func(page *ec2.DescribeNetworkInterfacesOutput) []types.NetworkInterface {
+		return page.NetworkInterfaces
+	})
+}
+
+var SecurityGroups = register("securityGroups", (*AWSFetch).getSecurityGroups)
+
+func (f *AWSFetch) getSecurityGroups(ctx context.Context) ([]types.SecurityGroup, error) {
+	paginator := ec2.NewDescribeSecurityGroupsPaginator(f.svc, &ec2.DescribeSecurityGroupsInput{
+		Filters: f.vpcFilter("vpc-id"),
+	})
+
+	return paginate(ctx, paginator, func(page *ec2.DescribeSecurityGroupsOutput) []types.SecurityGroup {
+		return page.SecurityGroups
+	})
+}
+
+var VPCEndpoints = register("vpcEndpoints", (*AWSFetch).getVpcEndpoints)
+
+func (f *AWSFetch) getVpcEndpoints(ctx context.Context) ([]types.VpcEndpoint, error) {
+	paginator := ec2.NewDescribeVpcEndpointsPaginator(f.svc, &ec2.DescribeVpcEndpointsInput{
+		Filters: f.vpcFilter("vpc-id"),
+	})
+
+	return paginate(ctx, paginator, func(page *ec2.DescribeVpcEndpointsOutput) []types.VpcEndpoint {
+		return page.VpcEndpoints
+	})
+}
+
+var NetworkACLs = register("networkAcls", (*AWSFetch).getNetworkAcls)
+
+func (f *AWSFetch) getNetworkAcls(ctx context.Context) ([]types.NetworkAcl, error) {
+	paginator := ec2.NewDescribeNetworkAclsPaginator(f.svc, &ec2.DescribeNetworkAclsInput{
+		Filters: f.vpcFilter("vpc-id"),
+	})
+
+	return paginate(ctx, paginator, func(page *ec2.DescribeNetworkAclsOutput) []types.NetworkAcl {
+		return page.NetworkAcls
+	})
+}
+
+var Addresses = register("addresses", (*AWSFetch).getAddresses)
+
+// getAddresses returns every elastic ip of the region. Addresses cannot be
+// filtered by vpc, as unassociated ones do not belong to any.
+func (f *AWSFetch) getAddresses(ctx context.Context) ([]types.Address, error) {
+	res, err := f.svc.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{})
+	if err != nil {
+		return []types.Address{}, err
 	}
+
+	return res.Addresses, nil
 }
 
-func (c *AWSChan) GetSecurityGroups(ctx context.Context) {
-	sgs := []types.SecurityGroup{}
-	paginator := ec2.NewDescribeSecurityGroupsPaginator(c.svc, &ec2.DescribeSecurityGroupsInput{})
-	
-	var err error
-	for paginator.HasMorePages() {
-		page, pageErr := paginator.NextPage(ctx)
-		if pageErr != nil {
-			err = pageErr
-			break
-		}
-		sgs = append(sgs, page.SecurityGroups...)
+var PrefixLists = register("prefixLists", (*AWSFetch).getPrefixLists, RouteTables.Name, SecurityGroups.Name)
+
+// getPrefixLists returns the managed prefix lists referenced by the routes
+// and security group rules fetched alongside it, each with its entries. A
+// list whose entries cannot be read is kept with Entries left nil, and the
+// error returned along with the rest.
+func (f *AWSFetch) getPrefixLists(ctx context.Context) ([]PrefixList, error) {
+	ids := f.referencedPrefixLists()
+	if len(ids) == 0 {
+		return []PrefixList{}, nil
 	}
 
-	c.SecurityGroups <- GetSecurityGroupsOutput{
-		SecurityGroups: sgs,
-		Err:            err,
+	// A filter rather than PrefixListIds, so lists that are no longer
+	// visible are left out instead of failing the call
+	paginator := ec2.NewDescribeManagedPrefixListsPaginator(f.svc, &ec2.DescribeManagedPrefixListsInput{
+		Filters: []types.Filter{{Name: aws.String("prefix-list-id"), Values: ids}},
+	})
+
+	managed, err := paginate(ctx, paginator, func(page *ec2.DescribeManagedPrefixListsOutput) []types.ManagedPrefixList {
+		return page.PrefixLists
+	})
+
+	prefixLists := []PrefixList{}
+	errs := []error{err}
+
+	for _, list := range managed {
+		entriesPaginator := ec2.NewGetManagedPrefixListEntriesPaginator(f.svc, &ec2.GetManagedPrefixListEntriesInput{
+			PrefixListId: list.PrefixListId,
+		})
+
+		entries, entriesErr := paginate(ctx, entriesPaginator, func(page *ec2.GetManagedPrefixListEntriesOutput) []types.PrefixListEntry {
+			return page.Entries
+		})
+		if entriesErr != nil {
+			entries = nil
+			errs = append(errs, fmt.Errorf("entries of %v: %w", aws.ToString(list.PrefixListId), entriesErr))
+		}
+
+		prefixLists = append(prefixLists, PrefixList{ManagedPrefixList: list, Entries: entries})
 	}
+
+	return prefixLists, errors.Join(errs...)
 }
 
-func (c *AWSChan) GetVpcEndpoints(ctx context.Context) {
-	endpoints := []types.VpcEndpoint{}
-	paginator := ec2.NewDescribeVpcEndpointsPaginator(c.svc, &ec2.DescribeVpcEndpointsInput{})
-	
-	var err error
-	for paginator.HasMorePages() {
-		page, pageErr := paginator.NextPage(ctx)
-		if pageErr != nil {
-			err = pageErr
-			break
+// referencedPrefixLists returns the ids of the prefix lists used by the
+// fetched route tables and security groups, each listed once
+func (f *AWSFetch) referencedPrefixLists() []string {
+	seen := make(map[string]bool)
+	ids := []string{}
+
+	add := func(id *string) {
+		if id == nil || seen[*id] {
+			return
 		}
-		endpoints = append(endpoints, page.VpcEndpoints...)
-	}
 
-	c.VPCEndpoints <- GetVPCEndpointsOutput{
-		VPCEndpoints: endpoints,
-		Err:          err,
+		seen[*id] = true
+		ids = append(ids, *id)
 	}
-}
 
-func (c *AWSChan) GetVolumes(ctx context.Context) {
-	volumes := []types.Volume{}
-	paginator := ec2.NewDescribeVolumesPaginator(c.svc, &ec2.DescribeVolumesInput{})
-	
-	var err error
-	for paginator.HasMorePages() {
-		page, pageErr := paginator.NextPage(ctx)
-		if pageErr != nil {
-			err = pageErr
-			break
+	for _, routeTable := range RouteTables.From(f).Data {
+		for _, route := range routeTable.Routes {
+			add(route.DestinationPrefixListId)
 		}
-		volumes = append(volumes, page.Volumes...)
 	}
 
-	c.Volumes <- GetVolumesOutput{
-		Volumes: volumes,
-		Err:     err,
+	for _, group := range SecurityGroups.From(f).Data {
+		for _, permission := range append(group.IpPermissions, group.IpPermissionsEgress...) {
+			for _, prefixList := range permission.PrefixListIds {
+				add(prefixList.PrefixListId)
+			}
+		}
 	}
+
+	return ids
 }
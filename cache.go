@@ -0,0 +1,225 @@
+// Copyright 2026 Stigian Consulting - reference license in top level of project
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"github.com/stigian/lsvpc/awsfetch"
+)
+
+const (
+	// defaultCacheTTL leaves the cache off unless -cache-ttl asks for it
+	defaultCacheTTL = 0
+	// watchCacheTTL is how long -watch reuses what it fetched when -cache-ttl isn't given
+	watchCacheTTL = time.Minute
+	// volatileTTLDivisor shortens the time resources that change often are cached for
+	volatileTTLDivisor = 5
+)
+
+// cacheTTL returns how long a resource is cached for. The layout of a vpc
+// rarely changes and is cached for the full -cache-ttl, volumes and
+// interfaces change more often, and instances, nat gateways and instance
+// status checks are always fetched fresh, their state being what -watch
+// and -diff-against compare. Prefix lists are never cached either, which
+// ones are fetched depends on the route tables and security groups
+// fetched alongside them.
+func cacheTTL(resource string) time.Duration {
+	ttl := Config.cacheTTL
+	if ttl <= 0 && Config.watch > 0 {
+		ttl = watchCacheTTL
+	}
+
+	switch resource {
+	case awsfetch.Instances.Name, awsfetch.NatGateways.Name, awsfetch.InstanceStatuses.Name, awsfetch.PrefixLists.Name:
+		return 0
+	case awsfetch.Volumes.Name, awsfetch.NetworkInterfaces.Name:
+		return ttl / volatileTTLDivisor
+	}
+
+	return ttl
+}
+
+// newCache returns the cache fetches of a region go through, nil if there is
+// none. -cache-ttl keeps results on disk for later runs, otherwise -watch
+// keeps them in memory between its refreshes. Snapshots and comparisons
+// always fetch fresh data, as they present it as the state of the account
+// at the time they run.
+func newCache(ctx context.Context, cfg aws.Config) awsfetch.Cache {
+	if Config.savePath != "" || Config.diffAgainst != "" {
+		return nil
+	}
+
+	if Config.cacheTTL > 0 {
+		if cache := newFileCache(ctx, cfg); cache != nil {
+			return cache
+		}
+
+		return nil
+	}
+
+	if Config.watch > 0 {
+		return &memCache{region: cfg.Region}
+	}
+
+	return nil
+}
+
+// fileCache is an awsfetch.Cache keeping each resource in a json file. Caching
+// is best effort, anything that cannot be read or written is fetched instead.
+type fileCache struct {
+	dir string
+}
+
+// accountIDs remembers the account behind each access key, so the caller
+// identity is only requested once per set of credentials.
+var accountIDs sync.Map
+
+// newFileCache returns the cache for a region of the account cfg's
+// credentials belong to, nil if caching is not possible.
+func newFileCache(ctx context.Context, cfg aws.Config) *fileCache {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return nil
+	}
+
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil
+	}
+
+	accountID, ok := accountIDs.Load(creds.AccessKeyID)
+	if !ok {
+		identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+		if err != nil {
+			return nil
+		}
+
+		accountID = aws.ToString(identity.Account)
+		accountIDs.Store(creds.AccessKeyID, accountID)
+	}
+
+	return &fileCache{
+		dir: filepath.Join(base, "lsvpc", accountID.(string), cfg.Region, filtersKey()),
+	}
+}
+
+// filtersKey separates cached data by everything that changes what is fetched
+func filtersKey() string {
+	key, _ := json.Marshal(struct {
+		VpcIDs      []string
+		Tags        map[string][]string
+		EndpointURL string
+	}{Config.vpcIDs, Config.tags, Config.endpointURL})
+	sum := sha256.Sum256(key)
+
+	return hex.EncodeToString(sum[:8])
+}
+
+func (c *fileCache) path(resource string) string {
+	return filepath.Join(c.dir, resource+".json")
+}
+
+func (c *fileCache) Get(resource string, v any) bool {
+	info, err := os.Stat(c.path(resource))
+	if err != nil || time.Since(info.ModTime()) > cacheTTL(resource) {
+		return false
+	}
+
+	data, err := os.ReadFile(c.path(resource))
+	if err != nil {
+		return false
+	}
+
+	return json.Unmarshal(data, v) == nil
+}
+
+func (c *fileCache) Put(resource string, v any) {
+	if cacheTTL(resource) <= 0 {
+		return
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+
+	if err := os.MkdirAll(c.dir, 0o700); err != nil { //nolint:gomnd // file permissions
+		return
+	}
+
+	// Written aside and renamed into place, so a concurrent run never reads half a file
+	tmp, err := os.CreateTemp(c.dir, resource+"-*.tmp")
+	if err != nil {
+		return
+	}
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+
+		return
+	}
+
+	if err := os.Rename(tmp.Name(), c.path(resource)); err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+// memCache is an awsfetch.Cache keeping results in memory, for -watch to
+// reuse between refreshes. -watch only ever fetches a single account.
+type memCache struct {
+	region string
+}
+
+type memEntry struct {
+	stored time.Time
+	data   []byte
+}
+
+// memCached holds the entries of every memCache, by region and resource
+var memCached sync.Map
+
+func (c *memCache) key(resource string) string {
+	return c.region + "/" + resource
+}
+
+func (c *memCache) Get(resource string, v any) bool {
+	cached, ok := memCached.Load(c.key(resource))
+	if !ok {
+		return false
+	}
+
+	entry, ok := cached.(memEntry)
+	if !ok || time.Since(entry.stored) > cacheTTL(resource) {
+		return false
+	}
+
+	return json.Unmarshal(entry.data, v) == nil
+}
+
+func (c *memCache) Put(resource string, v any) {
+	if cacheTTL(resource) <= 0 {
+		return
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+
+	memCached.Store(c.key(resource), memEntry{stored: time.Now(), data: data})
+}
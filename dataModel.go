@@ -3,23 +3,60 @@ package main
 
 import (
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"github.com/stigian/lsvpc/awsfetch"
 )
 
 type RegionData struct {
-	VPCs map[string]*VPC
+	VPCs       map[string]*VPC
+	ElasticIPs []*ElasticIP       // unassociated elastic ips, which belong to no vpc
+	Fetch      *awsfetch.AWSFetch // raw results the VPCs were built from, kept for snapshots
+	Errors     []*FetchError      // resources that could not be fetched, VPCs are built from the rest
+	Err        error              // the region as a whole could not be fetched
+}
+
+// AccountData holds the regions fetched from an account reached by -role-arn,
+// -org or one of several -profile. Accounts with neither a RoleARN nor a
+// Profile are fetched with the current credentials.
+type AccountData struct {
+	Regions map[string]RegionData
+	Err     error // the account could not be accessed, or its regions not listed
+	ID      string
+	Name    string // only known for accounts listed through -org
+	RoleARN string
+	Profile string
+}
+
+type AccountDataSorted struct {
+	Account string              `json:"account"`
+	Name    string              `json:"name,omitempty"`
+	RoleARN string              `json:"roleArn,omitempty"`
+	Profile string              `json:"profile,omitempty"`
+	Error   string              `json:"error,omitempty"`
+	Regions []*RegionDataSorted `json:"regions"`
 }
 
 type RegionDataSorted struct {
-	Region string `json:"region"`
-	VPCs   []*VPCSorted
+	Region     string `json:"region"`
+	Error      string `json:"error,omitempty"`
+	VPCs       []*VPCSorted
+	ElasticIPs []*ElasticIP  `json:"unassociatedElasticIps,omitempty"`
+	Errors     []*FetchError `json:"errors,omitempty"`
+}
+
+// FetchError describes a resource category missing from the output and why.
+type FetchError struct {
+	Resource string `json:"resource"`
+	Error    string `json:"error"`
 }
 
 type VPCData struct {
-	ID            string `json:"id"`
-	CidrBlock     string `json:"cidrBlock"`
-	IPv6CidrBlock string `json:"iPv6CidrBlock"`
-	Name          string `json:"name"`
-	IsDefault     bool   `json:"isDefault"`
+	Tags          map[string]string `json:"tags,omitempty"`
+	ID            string            `json:"id"`
+	CidrBlock     string            `json:"cidrBlock"`
+	IPv6CidrBlock string            `json:"iPv6CidrBlock"`
+	Name          string            `json:"name"`
+	IsDefault     bool              `json:"isDefault"`
 }
 
 type VPCSorted struct {
@@ -39,12 +76,17 @@ type VPC struct {
 
 type SubnetData struct {
 	RouteTable         *RouteTable
-	ID                 string `json:"id"`
-	CidrBlock          string `json:"cidrBlock"`
-	AvailabilityZone   string `json:"availabilityZone"`
-	AvailabilityZoneID string `json:"availabilityZoneId"`
-	Name               string `json:"name"`
-	Public             bool   `json:"public"`
+	NetworkACL         *NetworkACL       `json:"networkAcl,omitempty"`
+	Tags               map[string]string `json:"tags,omitempty"`
+	ID                 string            `json:"id"`
+	CidrBlock          string            `json:"cidrBlock"`
+	AvailabilityZone   string            `json:"availabilityZone"`
+	AvailabilityZoneID string            `json:"availabilityZoneId"`
+	Name               string            `json:"name"`
+	Class              string            `json:"class"`
+	// Public is only whether instances are launched with a public or
+	// customer-owned ip, the route table decides Class
+	Public bool `json:"public"`
 }
 
 type SubnetSorted struct {
@@ -69,18 +111,20 @@ type Subnet struct {
 }
 
 type InstanceData struct {
-	ID             string `json:"id"`
-	Type           string `json:"type"`
-	SubnetID       string `json:"subnetId"`
-	VpcID          string `json:"vpcId"`
-	State          string `json:"state"`
-	PublicIP       string `json:"publicIP"`
-	PrivateIP      string `json:"privateIP"`
-	Name           string `json:"name"`
-	InstanceStatus string `json:"instanceStatus"`
-	SystemStatus   string `json:"systemStatus"`
-	PlatformName   string `json:"platformName"`
-	PlatformType   string `json:"platformType"`
+	Tags           map[string]string `json:"tags,omitempty"`
+	ID             string            `json:"id"`
+	Type           string            `json:"type"`
+	SubnetID       string            `json:"subnetId"`
+	VpcID          string            `json:"vpcId"`
+	State          string            `json:"state"`
+	PublicIP       string            `json:"publicIP"`
+	PrivateIP      string            `json:"privateIP"`
+	Name           string            `json:"name"`
+	InstanceStatus string            `json:"instanceStatus"`
+	SystemStatus   string            `json:"systemStatus"`
+	PlatformName   string            `json:"platformName"`
+	PlatformType   string            `json:"platformType"`
+	ElasticIP      *ElasticIP        `json:"elasticIp,omitempty"` // set when PublicIP is an elastic ip
 }
 
 type InstanceSorted struct {
@@ -98,6 +142,7 @@ type Instance struct {
 
 type NetworkInterfaceData struct {
 	RawNetworkInterface types.NetworkInterface `json:"-"`
+	Tags                map[string]string      `json:"tags,omitempty"`
 	ID                  string                 `json:"id"`
 	PrivateIP           string                 `json:"privateIp"`
 	MAC                 string                 `json:"mAC"`
@@ -107,6 +152,7 @@ type NetworkInterfaceData struct {
 	PublicIP            string                 `json:"publicIp"`
 	Name                string                 `json:"name"`
 	SubnetID            string                 `json:"subnetId"` // we're just accounting for this for display purposes
+	ElasticIP           *ElasticIP             `json:"elasticIp,omitempty"`
 }
 
 type NetworkInterface struct {
@@ -130,12 +176,13 @@ type SecurityGroup struct {
 }
 
 type SecurityGroupRule struct {
-	IPProtocol string       `json:"ipProtocol"`
-	IPRanges   []*IPRange   `json:"ipRanges"`
-	IPv6Ranges []*IPv6Range `json:"ipv6Ranges"`
-	Groups     []*Group     `json:"groups"`
-	FromPort   int32        `json:"fromPort"`
-	ToPort     int32        `json:"toPort"`
+	IPProtocol  string        `json:"ipProtocol"`
+	IPRanges    []*IPRange    `json:"ipRanges"`
+	IPv6Ranges  []*IPv6Range  `json:"ipv6Ranges"`
+	Groups      []*Group      `json:"groups"`
+	PrefixLists []*PrefixList `json:"prefixLists"`
+	FromPort    int32         `json:"fromPort"`
+	ToPort      int32         `json:"toPort"`
 }
 
 type IPRange struct {
@@ -172,6 +219,7 @@ type NatGatewayData struct {
 	State         string           `json:"state"`
 	Type          string           `json:"type"`
 	Name          string           `json:"name"`
+	ElasticIP     *ElasticIP       `json:"elasticIp,omitempty"`
 }
 type NatGateway struct {
 	Interfaces map[string]*NetworkInterface `json:"interfaces"`
@@ -187,6 +235,66 @@ type RouteTable struct {
 	RawRoute types.RouteTable `json:"-"`
 	ID       string           `json:"id"`
 	Default  string           `json:"default"`
+	Main     bool             `json:"main"`
+	Routes   []*Route         `json:"routes"`
+}
+
+// Route is a single route of a route table. The destination is a cidr, an
+// ipv6 cidr or the id of a prefix list, which is then resolved in PrefixList.
+type Route struct {
+	Destination string      `json:"destination"`
+	PrefixList  *PrefixList `json:"prefixList,omitempty"`
+	Target      string      `json:"target"`
+	Origin      string      `json:"origin"`
+	State       string      `json:"state"` // active, or blackhole once the target is gone
+}
+
+// PrefixList is a managed prefix list referenced by a route or security
+// group rule. Entries is nil for lists that could not be fetched.
+type PrefixList struct {
+	ID      string             `json:"id"`
+	Name    string             `json:"name"`
+	Entries []*PrefixListEntry `json:"entries"`
+}
+
+type PrefixListEntry struct {
+	Cidr        string `json:"cidr"`
+	Description string `json:"description"`
+}
+
+// ElasticIP is an allocated elastic ip. The pool is "amazon" for addresses
+// from Amazon's pool, or the id of a BYOIP or customer owned pool.
+type ElasticIP struct {
+	RawAddress     types.Address     `json:"-"`
+	Tags           map[string]string `json:"tags,omitempty"`
+	AllocationID   string            `json:"allocationId"`
+	AssociationID  string            `json:"associationId,omitempty"`
+	PublicIP       string            `json:"publicIp"`
+	PublicIPv4Pool string            `json:"publicIpv4Pool"`
+	Name           string            `json:"name"`
+}
+
+type NetworkACL struct {
+	RawNetworkACL types.NetworkAcl  `json:"-"`
+	ID            string            `json:"id"`
+	Name          string            `json:"name"`
+	IsDefault     bool              `json:"isDefault"`
+	Inbound       []*NetworkACLRule `json:"inbound"`
+	Outbound      []*NetworkACLRule `json:"outbound"`
+}
+
+// NetworkACLRule is a network acl entry. Rules are kept in the order they
+// are evaluated in, the first rule matching a packet decides its fate.
+type NetworkACLRule struct {
+	RuleNumber    int32  `json:"ruleNumber"`
+	Action        string `json:"action"`
+	Protocol      string `json:"protocol"`
+	FromPort      int32  `json:"fromPort"`
+	ToPort        int32  `json:"toPort"`
+	ICMPType      int32  `json:"icmpType"`
+	ICMPCode      int32  `json:"icmpCode"`
+	CidrBlock     string `json:"cidrBlock,omitempty"`
+	IPv6CidrBlock string `json:"ipv6CidrBlock,omitempty"`
 }
 
 type TGWAttachment struct {
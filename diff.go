@@ -0,0 +1,852 @@
+// Copyright 2026 Stigian Consulting - reference license in top level of project
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RegionDiff lists what changed in one region between two listings
+type RegionDiff struct {
+	Account  string            `json:"account,omitempty"`
+	Name     string            `json:"name,omitempty"`
+	Profile  string            `json:"profile,omitempty"`
+	Region   string            `json:"region,omitempty"`
+	Error    string            `json:"error,omitempty"` // the region could not be compared
+	Warnings []string          `json:"warnings,omitempty"`
+	Changes  []*ResourceChange `json:"changes"`
+}
+
+// ResourceChange is a resource that was added, removed or modified
+type ResourceChange struct {
+	Change string         `json:"change"`
+	Kind   string         `json:"kind"`
+	ID     string         `json:"id"`
+	Name   string         `json:"name,omitempty"`
+	Parent string         `json:"parent,omitempty"`
+	Fields []*FieldChange `json:"fields,omitempty"`
+}
+
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+const (
+	changeAdded    = "added"
+	changeRemoved  = "removed"
+	changeModified = "modified"
+)
+
+// diffField is one compared property of a resource
+type diffField struct {
+	name  string
+	value string
+}
+
+// diffEntry is a resource as compared by diff, flattened out of the sorted model
+type diffEntry struct {
+	kind   string
+	id     string
+	name   string
+	parent string
+	fields []diffField
+}
+
+const (
+	ruleKind    = "security group rule"
+	aclRuleKind = "network acl rule"
+)
+
+func (e *diffEntry) key() string {
+	// Rules are only unique within their group or acl
+	if e.kind == ruleKind || e.kind == aclRuleKind {
+		return e.kind + "/" + e.parent + "/" + e.id
+	}
+
+	return e.kind + "/" + e.id
+}
+
+// diffEntries flattens a region into its resources, in the order they are listed
+type diffEntries struct {
+	entries []*diffEntry
+	index   map[string]*diffEntry
+}
+
+func (d *diffEntries) add(entry *diffEntry) {
+	// Peering connections within the region are listed under both of their vpcs
+	if _, ok := d.index[entry.key()]; ok {
+		return
+	}
+
+	d.entries = append(d.entries, entry)
+	d.index[entry.key()] = entry
+}
+
+func regionEntries(vpcs []*VPCSorted, eips []*ElasticIP) *diffEntries {
+	d := &diffEntries{index: make(map[string]*diffEntry)}
+	groups := make(map[string]*SecurityGroup)
+	acls := make(map[string]*NetworkACL)
+
+	for _, vpc := range vpcs {
+		d.add(&diffEntry{
+			kind: "vpc",
+			id:   vpc.ID,
+			name: vpc.Name,
+			fields: []diffField{
+				{"cidr", vpc.CidrBlock},
+				{"ipv6 cidr", vpc.IPv6CidrBlock},
+				{"default", fmt.Sprint(vpc.IsDefault)},
+				{"tags", formatTags(vpc.Tags)},
+			},
+		})
+
+		for _, gateway := range vpc.Gateways {
+			d.add(&diffEntry{kind: "gateway", id: gateway, parent: vpc.ID})
+		}
+
+		for _, peer := range vpc.Peers {
+			d.add(&diffEntry{
+				kind:   "peering connection",
+				id:     peer.ID,
+				name:   peer.Name,
+				parent: vpc.ID,
+				fields: []diffField{
+					{"requester", peer.Requester},
+					{"accepter", peer.Accepter},
+				},
+			})
+		}
+
+		for _, subnet := range vpc.Subnets {
+			subnetEntries(d, subnet, groups)
+
+			if subnet.NetworkACL != nil {
+				acls[subnet.NetworkACL.ID] = subnet.NetworkACL
+			}
+		}
+	}
+
+	aclIDs := []string{}
+	for aclID := range acls {
+		aclIDs = append(aclIDs, aclID)
+	}
+
+	sort.Strings(aclIDs)
+
+	for _, aclID := range aclIDs {
+		networkACLEntries(d, acls[aclID])
+	}
+
+	for _, eip := range eips {
+		d.add(&diffEntry{
+			kind: "unassociated elastic ip",
+			id:   eip.AllocationID,
+			name: eip.Name,
+			fields: []diffField{
+				{"public ip", eip.PublicIP},
+				{"pool", eip.PublicIPv4Pool},
+			},
+		})
+	}
+
+	groupIDs := []string{}
+	for groupID := range groups {
+		groupIDs = append(groupIDs, groupID)
+	}
+
+	sort.Strings(groupIDs)
+
+	for _, groupID := range groupIDs {
+		securityGroupEntries(d, groups[groupID])
+	}
+
+	return d
+}
+
+func subnetEntries(d *diffEntries, subnet *SubnetSorted, groups map[string]*SecurityGroup) {
+	defaultRoute := ""
+	if subnet.RouteTable != nil {
+		defaultRoute = subnet.RouteTable.Default
+	}
+
+	networkACL := ""
+	if subnet.NetworkACL != nil {
+		networkACL = subnet.NetworkACL.ID
+	}
+
+	d.add(&diffEntry{
+		kind: "subnet",
+		id:   subnet.ID,
+		name: subnet.Name,
+		fields: []diffField{
+			{"cidr", subnet.CidrBlock},
+			{"availability zone", subnet.AvailabilityZone},
+			{"default route", defaultRoute},
+			{"network acl", networkACL},
+			{"class", subnet.Class},
+			{"public", fmt.Sprint(subnet.Public)},
+			{"tags", formatTags(subnet.Tags)},
+		},
+	})
+
+	for _, endpoint := range subnet.InterfaceEndpoints {
+		ifaceIDs := []string{}
+
+		for _, iface := range endpoint.Interfaces {
+			ifaceIDs = append(ifaceIDs, iface.ID)
+			collectGroups(groups, iface.Groups)
+		}
+
+		d.add(&diffEntry{
+			kind:   "interface endpoint",
+			id:     endpoint.ID,
+			name:   endpoint.Name,
+			parent: subnet.ID,
+			fields: []diffField{
+				{"service", endpoint.ServiceName},
+				{"interfaces", strings.Join(ifaceIDs, ", ")},
+			},
+		})
+	}
+
+	for _, endpoint := range subnet.GatewayEndpoints {
+		d.add(&diffEntry{
+			kind:   "gateway endpoint",
+			id:     endpoint.ID,
+			name:   endpoint.Name,
+			parent: subnet.ID,
+			fields: []diffField{{"service", endpoint.ServiceName}},
+		})
+	}
+
+	for _, iface := range subnet.ENIs {
+		ifaceSorted := sortNetworkInterface(iface)
+		collectGroups(groups, ifaceSorted.Groups)
+		d.add(interfaceEntry(ifaceSorted, subnet.ID))
+	}
+
+	for _, instance := range subnet.Instances {
+		volumeIDs := []string{}
+		for _, volume := range instance.Volumes {
+			volumeIDs = append(volumeIDs, volume.ID)
+		}
+
+		d.add(&diffEntry{
+			kind:   "instance",
+			id:     instance.ID,
+			name:   instance.Name,
+			parent: subnet.ID,
+			fields: []diffField{
+				{"type", instance.Type},
+				{"state", instance.State},
+				{"private ip", instance.PrivateIP},
+				{"public ip", instance.PublicIP},
+				{"elastic ip", elasticIPAllocation(instance.ElasticIP)},
+				{"platform", instance.PlatformName},
+				{"volumes", strings.Join(volumeIDs, ", ")},
+				{"tags", formatTags(instance.Tags)},
+			},
+		})
+
+		for _, iface := range instance.Interfaces {
+			collectGroups(groups, iface.Groups)
+			d.add(interfaceEntry(iface, instance.ID))
+		}
+	}
+
+	for _, natGateway := range subnet.NatGateways {
+		for _, iface := range sortNatGateway(natGateway).Interfaces {
+			collectGroups(groups, iface.Groups)
+		}
+
+		d.add(&diffEntry{
+			kind:   "nat gateway",
+			id:     natGateway.ID,
+			name:   natGateway.Name,
+			parent: subnet.ID,
+			fields: []diffField{
+				{"type", natGateway.Type},
+				{"state", natGateway.State},
+				{"private ip", natGateway.PrivateIP},
+				{"public ip", natGateway.PublicIP},
+				{"elastic ip", elasticIPAllocation(natGateway.ElasticIP)},
+			},
+		})
+	}
+
+	for _, tgw := range subnet.TGWs {
+		d.add(&diffEntry{
+			kind:   "transit gateway attachment",
+			id:     tgw.AttachmentID,
+			name:   tgw.Name,
+			parent: subnet.ID,
+			fields: []diffField{{"transit gateway", tgw.TransitGatewayID}},
+		})
+	}
+}
+
+func interfaceEntry(iface *NetworkInterfaceSorted, parent string) *diffEntry {
+	groupIDs := []string{}
+	for _, group := range iface.Groups {
+		groupIDs = append(groupIDs, group.GroupID)
+	}
+
+	return &diffEntry{
+		kind:   "network interface",
+		id:     iface.ID,
+		name:   iface.Name,
+		parent: parent,
+		fields: []diffField{
+			{"type", iface.Type},
+			{"private ip", iface.PrivateIP},
+			{"public ip", iface.PublicIP},
+			{"elastic ip", elasticIPAllocation(iface.ElasticIP)},
+			{"description", iface.Description},
+			{"security groups", strings.Join(groupIDs, ", ")},
+			{"tags", formatTags(iface.Tags)},
+		},
+	}
+}
+
+// collectGroups gathers the security groups in use, they are only known
+// through the interfaces they are attached to.
+func collectGroups(groups map[string]*SecurityGroup, attached []*SecurityGroup) {
+	for _, group := range attached {
+		groups[group.GroupID] = group
+	}
+}
+
+// securityGroupEntries adds a security group and each of its rules. Rules
+// have no id of their own, a changed rule shows as one removed and one added.
+func securityGroupEntries(d *diffEntries, sg *SecurityGroup) {
+	d.add(&diffEntry{
+		kind: "security group",
+		id:   sg.GroupID,
+		name: sg.TagName,
+		fields: []diffField{
+			{"group name", sg.GroupName},
+			{"description", sg.Description},
+		},
+	})
+
+	for _, rule := range sg.IPPermissions {
+		d.add(&diffEntry{kind: ruleKind, id: describeRule(rule, true), parent: sg.GroupID})
+	}
+
+	for _, rule := range sg.IPPermissionsEgress {
+		d.add(&diffEntry{kind: ruleKind, id: describeRule(rule, false), parent: sg.GroupID})
+	}
+}
+
+// networkACLEntries adds a network acl and each of its rules. Rules are
+// identified by direction and number, as that is how AWS identifies them.
+func networkACLEntries(d *diffEntries, acl *NetworkACL) {
+	d.add(&diffEntry{
+		kind:   "network acl",
+		id:     acl.ID,
+		name:   acl.Name,
+		fields: []diffField{{"default", fmt.Sprint(acl.IsDefault)}},
+	})
+
+	for _, direction := range []string{"inbound", "outbound"} {
+		rules := acl.Inbound
+		if direction == "outbound" {
+			rules = acl.Outbound
+		}
+
+		for _, rule := range rules {
+			ruleNumber, proto, portRange, cidr := aclRuleParts(rule)
+
+			id := direction + " " + ruleNumber
+			if rule.RuleNumber == aclDefaultRule {
+				// An acl with ipv6 has a catch-all rule for each address family,
+				// both numbered 32767
+				family := "ipv4"
+				if rule.IPv6CidrBlock != "" {
+					family = "ipv6"
+				}
+
+				id += " " + family
+			}
+
+			d.add(&diffEntry{
+				kind:   aclRuleKind,
+				id:     id,
+				parent: acl.ID,
+				fields: []diffField{
+					{"action", rule.Action},
+					{"protocol", proto},
+					{"ports", portRange},
+					{"cidr", cidr},
+				},
+			})
+		}
+	}
+}
+
+// describeRule summarizes a security group rule the way printRules displays it,
+// including the groups it refers to.
+func describeRule(rule *SecurityGroupRule, ingress bool) string {
+	direction := "inbound"
+	if !ingress {
+		direction = "outbound"
+	}
+
+	proto := rule.IPProtocol
+	portRange := fmt.Sprintf("%v-%v", rule.FromPort, rule.ToPort)
+
+	if rule.FromPort == rule.ToPort {
+		portRange = fmt.Sprintf("%v", rule.FromPort)
+	}
+
+	switch {
+	case rule.IPProtocol == "-1":
+		proto = "all"
+		portRange = ""
+	case (rule.IPProtocol == "icmp" || rule.IPProtocol == "icmpv6") && (rule.FromPort == -1 || rule.ToPort == -1):
+		portRange = "all"
+	}
+
+	parts := []string{direction, proto}
+	if portRange != "" {
+		parts = append(parts, portRange)
+	}
+
+	for _, ipRange := range rule.IPRanges {
+		parts = append(parts, ipRange.CidrIP)
+	}
+
+	for _, ipRange := range rule.IPv6Ranges {
+		parts = append(parts, ipRange.CidrIPV6)
+	}
+
+	for _, group := range rule.Groups {
+		parts = append(parts, group.GroupId)
+	}
+
+	for _, prefixList := range rule.PrefixLists {
+		parts = append(parts, prefixList.ID)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func elasticIPAllocation(eip *ElasticIP) string {
+	if eip == nil {
+		return ""
+	}
+
+	return eip.AllocationID
+}
+
+func formatTags(tags map[string]string) string {
+	pairs := []string{}
+	for k, v := range tags {
+		pairs = append(pairs, k+"="+v)
+	}
+
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ", ")
+}
+
+// diffRegionEntries compares two flattened regions. Added and modified
+// resources follow the order of the new listing, removed ones that of the old.
+func diffRegionEntries(oldEntries, newEntries *diffEntries) []*ResourceChange {
+	changes := []*ResourceChange{}
+
+	for _, entry := range newEntries.entries {
+		before, ok := oldEntries.index[entry.key()]
+		if !ok {
+			changes = append(changes, newChange(changeAdded, entry))
+
+			continue
+		}
+
+		fields := []*FieldChange{}
+
+		for i, field := range entry.fields {
+			if before.fields[i].value != field.value {
+				fields = append(fields, &FieldChange{
+					Field: field.name,
+					Old:   before.fields[i].value,
+					New:   field.value,
+				})
+			}
+		}
+
+		if before.name != entry.name {
+			fields = append(fields, &FieldChange{Field: "name", Old: before.name, New: entry.name})
+		}
+
+		if before.parent != entry.parent {
+			fields = append(fields, &FieldChange{Field: "parent", Old: before.parent, New: entry.parent})
+		}
+
+		if len(fields) > 0 {
+			change := newChange(changeModified, entry)
+			change.Fields = fields
+			changes = append(changes, change)
+		}
+	}
+
+	for _, entry := range oldEntries.entries {
+		if _, ok := newEntries.index[entry.key()]; !ok {
+			changes = append(changes, newChange(changeRemoved, entry))
+		}
+	}
+
+	return changes
+}
+
+func newChange(change string, entry *diffEntry) *ResourceChange {
+	return &ResourceChange{
+		Change: change,
+		Kind:   entry.kind,
+		ID:     entry.id,
+		Name:   entry.name,
+		Parent: entry.parent,
+	}
+}
+
+// accountKey matches accounts across listings, several profiles may lead
+// into the same account.
+func accountKey(accountData *AccountData) string {
+	return accountData.Profile + "/" + accountData.ID
+}
+
+// diffAccounts compares every region of every account between two listings.
+// Accounts and regions found on one side only are entirely added or removed.
+func diffAccounts(oldAccounts, newAccounts []*AccountData) []*RegionDiff {
+	oldIdx := make(map[string]*AccountData)
+	for _, accountData := range oldAccounts {
+		oldIdx[accountKey(accountData)] = accountData
+	}
+
+	newIdx := make(map[string]*AccountData)
+	for _, accountData := range newAccounts {
+		newIdx[accountKey(accountData)] = accountData
+	}
+
+	diffs := []*RegionDiff{}
+
+	for _, accountData := range newAccounts {
+		diffs = append(diffs, diffAccount(oldIdx[accountKey(accountData)], accountData)...)
+	}
+
+	for _, accountData := range oldAccounts {
+		if _, ok := newIdx[accountKey(accountData)]; !ok {
+			diffs = append(diffs, diffAccount(accountData, nil)...)
+		}
+	}
+
+	return diffs
+}
+
+// diffAccount compares the regions of an account, either side may be nil
+func diffAccount(oldAccount, newAccount *AccountData) []*RegionDiff {
+	known := newAccount
+	if known == nil {
+		known = oldAccount
+	}
+
+	header := RegionDiff{
+		Account: known.ID,
+		Name:    known.Name,
+		Profile: known.Profile,
+	}
+
+	for _, accountData := range []*AccountData{oldAccount, newAccount} {
+		if accountData != nil && accountData.Err != nil {
+			header.Error = accountData.Err.Error()
+			header.Changes = []*ResourceChange{}
+
+			return []*RegionDiff{&header}
+		}
+	}
+
+	regions := make(map[string]bool)
+
+	for _, accountData := range []*AccountData{oldAccount, newAccount} {
+		if accountData == nil {
+			continue
+		}
+
+		for region := range accountData.Regions {
+			regions[region] = true
+		}
+	}
+
+	regionKeys := []string{}
+	for region := range regions {
+		regionKeys = append(regionKeys, region)
+	}
+
+	sort.Strings(regionKeys)
+
+	diffs := []*RegionDiff{}
+
+	for _, region := range regionKeys {
+		regionDiff := header
+		regionDiff.Region = region
+		regionDiff.Changes = []*ResourceChange{}
+
+		oldData, newData := accountRegion(oldAccount, region), accountRegion(newAccount, region)
+
+		if err := firstErr(oldData.Err, newData.Err); err != nil {
+			regionDiff.Error = err.Error()
+			diffs = append(diffs, &regionDiff)
+
+			continue
+		}
+
+		regionDiff.Warnings = append(
+			diffWarnings("old", oldData.Errors),
+			diffWarnings("new", newData.Errors)...,
+		)
+		regionDiff.Changes = diffRegionEntries(
+			regionEntries(sortVPCs(oldData.VPCs), sortElasticIPs(oldData.ElasticIPs)),
+			regionEntries(sortVPCs(newData.VPCs), sortElasticIPs(newData.ElasticIPs)),
+		)
+		diffs = append(diffs, &regionDiff)
+	}
+
+	return diffs
+}
+
+// accountRegion returns a region of an account, empty if either is missing
+func accountRegion(accountData *AccountData, region string) RegionData {
+	if accountData == nil {
+		return RegionData{}
+	}
+
+	return accountData.Regions[region]
+}
+
+func firstErr(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// diffWarnings flags resources missing from one side, which would otherwise
+// show up as removed or added.
+func diffWarnings(side string, errs []*FetchError) []string {
+	warnings := []string{}
+
+	for _, fetchErr := range errs {
+		warnings = append(warnings, fmt.Sprintf("%v are missing from the %v listing: %v", fetchErr.Resource, side, fetchErr.Error))
+	}
+
+	return warnings
+}
+
+// diffsFailed reports whether any region could not be compared
+func diffsFailed(diffs []*RegionDiff) bool {
+	for _, regionDiff := range diffs {
+		if regionDiff.Error != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func printDiffs(diffs []*RegionDiff) {
+	if Config.jsonOutput {
+		export, _ := json.Marshal(diffs)
+		fmt.Printf("%s\n", export)
+
+		return
+	}
+
+	if len(diffs) == 0 {
+		fmt.Println("No changes")
+
+		return
+	}
+
+	// Headers are only needed to tell several accounts or regions apart
+	headers := len(diffs) > 1
+	lastAccount := ""
+
+	for _, regionDiff := range diffs {
+		account := &AccountData{ID: regionDiff.Account, Name: regionDiff.Name, Profile: regionDiff.Profile}
+		if title := accountTitle(account); title != "" && title != lastAccount {
+			fmt.Printf("###%v###\n", title)
+			lastAccount = title
+		}
+
+		if headers && regionDiff.Region != "" {
+			fmt.Printf("===%v===\n", regionDiff.Region)
+		}
+
+		printRegionDiff(regionDiff)
+	}
+}
+
+func printRegionDiff(regionDiff *RegionDiff) {
+	if regionDiff.Error != "" {
+		fmt.Printf("%vCould not compare: %v%v\n", color.Red, regionDiff.Error, color.Reset)
+		lineFeed()
+
+		return
+	}
+
+	for _, warning := range regionDiff.Warnings {
+		fmt.Printf("%vWarning: %v%v\n", color.Red, warning, color.Reset)
+	}
+
+	if len(regionDiff.Changes) == 0 {
+		fmt.Println("No changes")
+	}
+
+	for _, change := range regionDiff.Changes {
+		printChange(change)
+	}
+
+	lineFeed()
+}
+
+func printChange(change *ResourceChange) {
+	mark, markColor := "~", color.Yellow
+
+	switch change.Change {
+	case changeAdded:
+		mark, markColor = "+", color.Green
+	case changeRemoved:
+		mark, markColor = "-", color.Red
+	}
+
+	parent := ""
+	if change.Parent != "" {
+		parent = " in " + change.Parent
+	}
+
+	fmt.Printf(
+		"%v%v %v %v%v%v%v\n",
+		markColor,
+		mark,
+		change.Kind,
+		change.ID,
+		formatName(change.Name),
+		color.Reset,
+		parent,
+	)
+
+	for _, field := range change.Fields {
+		fmt.Printf(
+			"%s%v: %v%v%v -> %v%v%v\n",
+			indent(4), //nolint:gomnd // not a magic number, spaces to indent by
+			field.Field,
+			color.Red,
+			orNone(field.Old),
+			color.Reset,
+			color.Green,
+			orNone(field.New),
+			color.Reset,
+		)
+	}
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+
+	return value
+}
+
+// doDiff compares two snapshot files, given as the arguments of `lsvpc diff`
+func doDiff(args []string) int {
+	if len(args) != 2 { //nolint:gomnd // old and new snapshot
+		fmt.Println("Usage: lsvpc diff [flags] <old snapshot> <new snapshot>")
+		return 1
+	}
+
+	sides := [][]*AccountData{}
+
+	for _, path := range args {
+		snapshot, err := readSnapshot(path)
+		if err != nil {
+			fmt.Printf("Failed to load snapshot: %v\n", err)
+			return 1
+		}
+
+		sides = append(sides, snapshotAccounts(snapshot))
+	}
+
+	diffs := diffAccounts(sides[0], sides[1])
+	printDiffs(diffs)
+
+	if diffsFailed(diffs) {
+		return 1
+	}
+
+	return 0
+}
+
+// diffAgainst compares a live run against the -diff-against snapshot, in
+// place of printing the listing. A live run and a snapshot need not cover the
+// same regions, so only the regions of an account both hold are compared.
+func diffAgainst(accounts []*AccountData) int {
+	snapshot, err := readSnapshot(Config.diffAgainst)
+	if err != nil {
+		fmt.Printf("Failed to load snapshot: %v\n", err)
+		return 1
+	}
+
+	oldAccounts := snapshotAccounts(snapshot)
+
+	oldIdx := make(map[string]*AccountData)
+	for _, accountData := range oldAccounts {
+		oldIdx[accountKey(accountData)] = accountData
+	}
+
+	newAccounts := []*AccountData{}
+
+	for _, accountData := range accounts {
+		oldAccount, ok := oldIdx[accountKey(accountData)]
+		if ok && accountData.Err == nil {
+			oldIdx[accountKey(accountData)] = sharedRegions(oldAccount, accountData)
+			accountData = sharedRegions(accountData, oldAccount)
+		}
+
+		newAccounts = append(newAccounts, accountData)
+	}
+
+	for i, accountData := range oldAccounts {
+		oldAccounts[i] = oldIdx[accountKey(accountData)]
+	}
+
+	diffs := diffAccounts(oldAccounts, newAccounts)
+	printDiffs(diffs)
+
+	if diffsFailed(diffs) {
+		return 1
+	}
+
+	return 0
+}
+
+// sharedRegions returns a copy of an account holding only the regions other also holds
+func sharedRegions(accountData, other *AccountData) *AccountData {
+	shared := *accountData
+	shared.Regions = make(map[string]RegionData)
+
+	for region, regionData := range accountData.Regions {
+		if _, ok := other.Regions[region]; ok {
+			shared.Regions[region] = regionData
+		}
+	}
+
+	return &shared
+}
@@ -4,6 +4,7 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"strings"
 )
 
@@ -62,13 +63,25 @@ func formatName(name string) string {
 	return fmt.Sprintf(" [%s]", string(runes))
 }
 
+func printAccountsJSON(accounts []*AccountDataSorted) {
+	export, _ := json.Marshal(accounts)
+	fmt.Printf("%v", string(export))
+}
+
 func printRegionsJSON(regions []*RegionDataSorted) {
 	export, _ := json.Marshal(regions)
 	fmt.Printf("%v", string(export))
 }
 
-func printVPCsJSON(vpcs []*VPCSorted) {
-	export, _ := json.Marshal(vpcs)
+// printRegionJSONLine prints a region as a single line of ndjson, for -stream
+func printRegionJSONLine(region *RegionDataSorted) {
+	export, _ := json.Marshal(region)
+	fmt.Printf("%v\n", string(export))
+}
+
+// printRegionJSON prints a single region in the same shape -a gives each region
+func printRegionJSON(region *RegionDataSorted) {
+	export, _ := json.Marshal(region)
 	fmt.Printf("%v", string(export))
 }
 
@@ -122,7 +135,7 @@ func printPeer(peer *VPCPeer, vpc *VPCSorted) {
 	}
 
 	fmt.Printf(
-		"%s%v%v%v%v %v %v%v%v\n",
+		"%s%v%v%v%v %v %v%v%v%v\n",
 		indent(4), //nolint:gomnd // not a magic number, spaces to indent by
 		color.Cyan,
 		peer.ID,
@@ -132,22 +145,29 @@ func printPeer(peer *VPCPeer, vpc *VPCSorted) {
 		color.Green,
 		vpcOperand,
 		color.Reset,
+		changeMark(peer.ID),
 	)
 }
 
 func printSubnet(subnet *SubnetSorted) {
 	// Print Subnet Info
-	public := "Private"
-	if subnet.Public {
-		public = "Public"
+	class := subnet.Class
+	if subnet.Public && class != subnetPublic {
+		// instances get public ips that no internet gateway route serves
+		class += " (maps public ip on launch)"
 	}
 
 	if Config.HideIP {
 		subnet.CidrBlock = expungedCIDR
 	}
 
+	defaultRoute := ""
+	if subnet.RouteTable != nil {
+		defaultRoute = subnet.RouteTable.Default
+	}
+
 	fmt.Printf(
-		"%s%v%v%v%v  %v  %v %v-->%v%v %v\n",
+		"%s%v%v%v%v  %v  %v %v-->%v%v %v%v\n",
 		indent(4), //nolint:gomnd // not a magic number, spaces to indent by
 		color.Blue,
 		subnet.ID,
@@ -156,21 +176,23 @@ func printSubnet(subnet *SubnetSorted) {
 		subnet.AvailabilityZone,
 		subnet.CidrBlock,
 		color.Yellow,
-		subnet.RouteTable.Default,
+		defaultRoute,
 		color.Reset,
-		public,
+		class,
+		changeMark(subnet.ID),
 	)
 }
 
 func printInterfaceEndpoint(interfaceEndpoint *InterfaceEndpointSorted, subnet *SubnetSorted) {
 	fmt.Printf(
-		"%s%v%v%v%v interface--> %v\n",
+		"%s%v%v%v%v interface--> %v%v\n",
 		indent(8), //nolint:gomnd // not a magic number, spaces to indent by
 		color.Cyan,
 		interfaceEndpoint.ID,
 		formatName(interfaceEndpoint.Name),
 		color.Reset,
 		interfaceEndpoint.ServiceName,
+		changeMark(interfaceEndpoint.ID),
 	)
 
 	for ifaceIdx := range interfaceEndpoint.Interfaces {
@@ -210,13 +232,14 @@ func printInterfaceEndpoint(interfaceEndpoint *InterfaceEndpointSorted, subnet *
 
 func printGatewayEndpoint(gatewayEndpoint *GatewayEndpoint) {
 	fmt.Printf(
-		"%s%v%v%v%v gateway--> %v\n",
+		"%s%v%v%v%v gateway--> %v%v\n",
 		indent(8), //nolint:gomnd // not a magic number, spaces to indent by
 		color.Cyan,
 		gatewayEndpoint.ID,
 		formatName(gatewayEndpoint.Name),
 		color.Reset,
 		gatewayEndpoint.ServiceName,
+		changeMark(gatewayEndpoint.ID),
 	)
 }
 
@@ -232,7 +255,7 @@ func printNetworkInterface(iface *NetworkInterface) {
 	}
 
 	fmt.Printf(
-		"%s%v%v%v%v %v %v %v %v %v : %v\n",
+		"%s%v%v%v%v %v %v %v %v %v : %v%v\n",
 		indent(8), //nolint:gomnd // not a magic number, spaces to indent by
 		color.Cyan,
 		iface.ID,
@@ -240,10 +263,11 @@ func printNetworkInterface(iface *NetworkInterface) {
 		color.Reset,
 		iface.Type,
 		iface.MAC,
-		iface.PublicIP,
+		iface.PublicIP+elasticIPMark(iface.ElasticIP),
 		iface.PrivateIP,
 		iface.DNS,
 		iface.Description,
+		changeMark(iface.ID),
 	)
 
 	if Config.Verbose {
@@ -272,7 +296,7 @@ func printInstance(instance *InstanceSorted) {
 	}
 
 	fmt.Printf(
-		"%s%v%s%v%v%v %v %v -- %v (%v/2) -- %v%v%v -- %v%v\n",
+		"%s%v%s%v%v%v %v %v -- %v (%v/2) -- %v%v%v -- %v%v%v\n",
 		indent(8), //nolint:gomnd // not a magic number, spaces to indent by
 		color.Cyan,
 		instance.ID,
@@ -284,10 +308,11 @@ func printInstance(instance *InstanceSorted) {
 		instance.State,
 		status,
 		color.Yellow,
-		instance.PublicIP,
+		instance.PublicIP+elasticIPMark(instance.ElasticIP),
 		color.Reset,
 		color.Cyan,
 		instance.PrivateIP,
+		changeMark(instance.ID),
 	)
 }
 
@@ -352,7 +377,7 @@ func printNatGateway(natGateway *NatGateway) {
 	}
 
 	fmt.Printf(
-		"%s%v%v%v%v  %v  %v  %v  %v\n",
+		"%s%v%v%v%v  %v  %v  %v  %v%v\n",
 		indent(8), //nolint:gomnd // not a magic number, spaces to indent by
 		color.Cyan,
 		natGateway.ID,
@@ -360,8 +385,9 @@ func printNatGateway(natGateway *NatGateway) {
 		color.Reset,
 		natGateway.Type,
 		natGateway.State,
-		natGateway.PublicIP,
+		natGateway.PublicIP+elasticIPMark(natGateway.ElasticIP),
 		natGateway.PrivateIP,
+		changeMark(natGateway.ID),
 	)
 
 	if Config.Verbose {
@@ -373,9 +399,51 @@ func printNatGateway(natGateway *NatGateway) {
 	}
 }
 
+// elasticIPMark tells an elastic ip apart from an ephemeral public ip,
+// naming its allocation in verbose mode
+func elasticIPMark(eip *ElasticIP) string {
+	switch {
+	case eip == nil:
+		return ""
+	case Config.Verbose:
+		return fmt.Sprintf(" (eip %v)", eip.AllocationID)
+	}
+
+	return " (eip)"
+}
+
+// printElasticIPs lists the unassociated elastic ips of a region
+func printElasticIPs(eips []*ElasticIP) {
+	if len(eips) == 0 {
+		return
+	}
+
+	fmt.Printf("%vUnassociated elastic ips:%v\n", color.Yellow, color.Reset)
+
+	for _, eip := range eips {
+		publicIP := eip.PublicIP
+		if Config.HideIP {
+			publicIP = expungedIP
+		}
+
+		fmt.Printf(
+			"%s%v%v%v%v  %v  %v\n",
+			indent(4), //nolint:gomnd // not a magic number, spaces to indent by
+			color.Cyan,
+			eip.AllocationID,
+			formatName(eip.Name),
+			color.Reset,
+			publicIP,
+			eip.PublicIPv4Pool,
+		)
+	}
+
+	lineFeed()
+}
+
 func printTGWAttachment(tgw *TGWAttachment) {
 	fmt.Printf(
-		"%s%v%v%v%v ---> %v%v%v\n",
+		"%s%v%v%v%v ---> %v%v%v%v\n",
 		indent(8), //nolint:gomnd // not a magic number, spaces to indent by
 		color.Cyan,
 		tgw.AttachmentID,
@@ -384,6 +452,7 @@ func printTGWAttachment(tgw *TGWAttachment) {
 		color.Yellow,
 		tgw.TransitGatewayID,
 		color.Reset,
+		changeMark(tgw.AttachmentID),
 	)
 }
 
@@ -433,7 +502,238 @@ func printRules(rules []*SecurityGroupRule, ind int, ingress bool) {
 			fmt.Printf("%v ", ipRange.CidrIPV6)
 		}
 
+		for _, prefixList := range rule.PrefixLists {
+			fmt.Printf("%v%v ", prefixList.ID, formatName(prefixList.Name))
+		}
+
 		fmt.Printf("\n")
+
+		for _, prefixList := range rule.PrefixLists {
+			printPrefixListEntries(prefixList, ind+8) //nolint:gomnd // not a magic number, spaces to indent by
+		}
+	}
+}
+
+// printPrefixListEntries lists the cidrs a referenced prefix list stands for
+func printPrefixListEntries(prefixList *PrefixList, ind int) {
+	for _, entry := range prefixList.Entries {
+		cidr := entry.Cidr
+		if Config.HideIP {
+			cidr = expungedCIDR
+		}
+
+		fmt.Printf("%s%v%v%v  %v\n", indent(ind), color.Purple, cidr, color.Reset, entry.Description)
+	}
+}
+
+// printPrefixListRoutes prints the routes of a subnet whose destination is a prefix list
+func printPrefixListRoutes(routeTable *RouteTable) {
+	for _, route := range routeTable.Routes {
+		if route.PrefixList == nil {
+			continue
+		}
+
+		fmt.Printf(
+			"%s%v%v%v%v route--> %v%v%v\n",
+			indent(8), //nolint:gomnd // not a magic number, spaces to indent by
+			color.Purple,
+			route.PrefixList.ID,
+			formatName(route.PrefixList.Name),
+			color.Reset,
+			color.Yellow,
+			route.Target,
+			color.Reset,
+		)
+		printPrefixListEntries(route.PrefixList, 12) //nolint:gomnd // not a magic number, spaces to indent by
+	}
+}
+
+// printRouteTable prints every route of a subnet's route table for -routes,
+// in the order AWS returns them
+func printRouteTable(routeTable *RouteTable) {
+	if routeTable == nil {
+		fmt.Printf("%s%vno route table%v\n", indent(8), color.Red, color.Reset) //nolint:gomnd // not a magic number, spaces to indent by
+
+		return
+	}
+
+	for _, route := range routeTable.Routes {
+		destination := route.Destination
+		if route.PrefixList != nil {
+			destination += formatName(route.PrefixList.Name)
+		} else if Config.HideIP {
+			destination = expungedCIDR
+		}
+
+		state := route.State
+		if state == "blackhole" {
+			state = fmt.Sprintf("%vBLACKHOLE%v", color.Red, color.Reset)
+		}
+
+		fmt.Printf(
+			"%s%-20v %v-->%v %-22v %-28v %v\n",
+			indent(8), //nolint:gomnd // not a magic number, spaces to indent by
+			destination,
+			color.Yellow,
+			color.Reset,
+			route.Target,
+			route.Origin,
+			state,
+		)
+
+		if Config.Verbose && route.PrefixList != nil {
+			printPrefixListEntries(route.PrefixList, 12) //nolint:gomnd // not a magic number, spaces to indent by
+		}
+	}
+}
+
+// printRoutes is the -routes listing, each subnet followed by its routes
+// instead of the resources within it
+func printRoutes(vpcs []*VPCSorted) {
+	for _, vpc := range vpcs {
+		printVPC(vpc)
+		fmt.Printf("%v\n", changeMark(vpc.ID)) // this linefeed is non-configurable
+
+		for _, subnet := range vpc.Subnets {
+			routeTable := ""
+
+			if subnet.RouteTable != nil {
+				routeTable = subnet.RouteTable.ID
+				if subnet.RouteTable.Main {
+					routeTable += " (main)"
+				}
+			}
+
+			if Config.HideIP {
+				subnet.CidrBlock = expungedCIDR
+			}
+
+			fmt.Printf(
+				"%s%v%v%v%v  %v  %v  %v%v%v%v\n",
+				indent(4), //nolint:gomnd // not a magic number, spaces to indent by
+				color.Blue,
+				subnet.ID,
+				formatName(subnet.Name),
+				color.Reset,
+				subnet.AvailabilityZone,
+				subnet.CidrBlock,
+				color.Purple,
+				routeTable,
+				color.Reset,
+				changeMark(subnet.ID),
+			)
+			printRouteTable(subnet.RouteTable)
+			lineFeed()
+		}
+	}
+}
+
+// printListing prints the vpcs of a region in the chosen text layout
+func printListing(vpcs []*VPCSorted) {
+	if Config.routes {
+		printRoutes(vpcs)
+
+		return
+	}
+
+	printVPCs(vpcs)
+}
+
+// aclProtocols names the protocol numbers network acls commonly use
+var aclProtocols = map[string]string{
+	"-1": "all",
+	"1":  "icmp",
+	"6":  "tcp",
+	"17": "udp",
+	"58": "icmpv6",
+}
+
+// aclDefaultRule is the number of the catch-all rule every network acl ends with
+const aclDefaultRule = 32767
+
+func printNetworkACL(acl *NetworkACL) {
+	isDefault := ""
+	if acl.IsDefault {
+		isDefault = " (default)"
+	}
+
+	fmt.Printf(
+		"%s%v%v%v%v%v\n",
+		indent(8), //nolint:gomnd // not a magic number, spaces to indent by
+		color.Purple,
+		acl.ID,
+		formatName(acl.Name),
+		color.Reset,
+		isDefault,
+	)
+	printACLRules(acl.Inbound, "inbound")
+	printACLRules(acl.Outbound, "outbound")
+}
+
+// aclRuleParts describes the rule number, protocol, port range and cidr of a
+// network acl rule for display
+func aclRuleParts(rule *NetworkACLRule) (string, string, string, string) {
+	ruleNumber := fmt.Sprintf("%v", rule.RuleNumber)
+	if rule.RuleNumber == aclDefaultRule {
+		ruleNumber = "*"
+	}
+
+	proto, ok := aclProtocols[rule.Protocol]
+	if !ok {
+		proto = rule.Protocol
+	}
+
+	portRange := ""
+
+	switch proto {
+	case "tcp", "udp":
+		portRange = fmt.Sprintf("%v-%v", rule.FromPort, rule.ToPort)
+		if rule.FromPort == rule.ToPort {
+			portRange = fmt.Sprintf("%v", rule.FromPort)
+		}
+	case "icmp", "icmpv6":
+		portRange = "all"
+		if rule.ICMPType != -1 {
+			portRange = fmt.Sprintf("type %v code %v", rule.ICMPType, rule.ICMPCode)
+		}
+	}
+
+	cidr := rule.CidrBlock
+	if rule.IPv6CidrBlock != "" {
+		cidr = rule.IPv6CidrBlock
+	}
+
+	return ruleNumber, proto, portRange, cidr
+}
+
+func printACLRules(rules []*NetworkACLRule, direction string) {
+	for _, rule := range rules {
+		ruleNumber, proto, portRange, cidr := aclRuleParts(rule)
+
+		action := fmt.Sprintf("%v%-5v%v", color.Green, rule.Action, color.Reset)
+		if rule.Action == "deny" {
+			action = fmt.Sprintf("%v%-5v%v", color.Red, rule.Action, color.Reset)
+		}
+
+		if Config.HideIP {
+			cidr = expungedCIDR
+		}
+
+		fmt.Printf(
+			"%s%v%-8v%v %-5v %v %v%v %v%v%v %v\n",
+			indent(12), //nolint:gomnd // not a magic number, spaces to indent by
+			color.Blue,
+			direction,
+			color.Reset,
+			ruleNumber,
+			action,
+			color.Cyan,
+			proto,
+			color.Yellow,
+			portRange,
+			color.Reset,
+			cidr,
+		)
 	}
 }
 
@@ -450,7 +750,7 @@ func printSecurityGroup(sg *SecurityGroup, ind int) {
 	printRules(sg.IPPermissionsEgress, ind, false)
 }
 
-func printVPCs(vpcs []*VPCSorted) {
+func initColor() {
 	if !Config.noColor {
 		color.Reset = "\033[0m"
 		color.Red = "\033[31m"
@@ -461,7 +761,49 @@ func printVPCs(vpcs []*VPCSorted) {
 		color.Cyan = "\033[36m"
 		color.White = "\033[37m"
 	}
+}
+
+// printFetchErrors flags resource categories that are missing from the output
+func printFetchErrors(out io.Writer, errs []*FetchError) {
+	for _, fetchErr := range errs {
+		fmt.Fprintf(
+			out,
+			"%vWarning: %v could not be fetched and are missing from the output: %v%v\n",
+			color.Red,
+			fetchErr.Resource,
+			fetchErr.Error,
+			color.Reset,
+		)
+	}
+
+	if len(errs) > 0 && !Config.noSpace {
+		fmt.Fprintln(out)
+	}
+}
+
+// printRegionFailure replaces the listing of a region that could not be fetched at all
+func printRegionFailure(reason string) {
+	fmt.Printf(
+		"%vFailed to fetch region: %v%v\n",
+		color.Red,
+		reason,
+		color.Reset,
+	)
+	lineFeed()
+}
 
+// printAccountFailure replaces the listing of an account that could not be accessed
+func printAccountFailure(reason string) {
+	fmt.Printf(
+		"%vFailed to access account: %v%v\n",
+		color.Red,
+		reason,
+		color.Reset,
+	)
+	lineFeed()
+}
+
+func printVPCs(vpcs []*VPCSorted) {
 	// sort the keys
 	for vpcIdx := range vpcs {
 		vpc := vpcs[vpcIdx]
@@ -473,7 +815,7 @@ func printVPCs(vpcs []*VPCSorted) {
 			printGateway(gateway)
 		}
 
-		fmt.Printf("\n") // this linefeed is non-configurable
+		fmt.Printf("%v\n", changeMark(vpc.ID)) // this linefeed is non-configurable
 
 		// Print Peers
 		peersExist := false
@@ -493,6 +835,14 @@ func printVPCs(vpcs []*VPCSorted) {
 			subnet := vpc.Subnets[subnetIdx]
 			printSubnet(subnet)
 
+			if Config.Verbose && subnet.RouteTable != nil {
+				printPrefixListRoutes(subnet.RouteTable)
+			}
+
+			if Config.Verbose && subnet.NetworkACL != nil {
+				printNetworkACL(subnet.NetworkACL)
+			}
+
 			// Print Endpoints
 			for interfaceEndpointIdx := range subnet.InterfaceEndpoints {
 				interfaceEndpoint := subnet.InterfaceEndpoints[interfaceEndpointIdx]
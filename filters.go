@@ -0,0 +1,160 @@
+// Copyright 2026 Stigian Consulting - reference license in top level of project
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// tagFlag collects repeated -tag Key=Value flags. A key given more than once
+// matches any of its values, every key has to match.
+type tagFlag map[string][]string
+
+func (t *tagFlag) String() string {
+	pairs := []string{}
+
+	for key, values := range *t {
+		for _, value := range values {
+			pairs = append(pairs, fmt.Sprintf("%v=%v", key, value))
+		}
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func (t *tagFlag) Set(value string) error {
+	key, val, found := strings.Cut(value, "=")
+	if key == "" {
+		return fmt.Errorf("expected Key=Value, got '%v'", value)
+	}
+
+	if !found {
+		val = "*" // a bare key matches any value
+	}
+
+	if *t == nil {
+		*t = make(tagFlag)
+	}
+
+	(*t)[key] = append((*t)[key], val)
+
+	return nil
+}
+
+// globMatch matches a value against a pattern using the same wildcards as ec2
+// filters: * for any run of characters and ? for any single character.
+func globMatch(pattern, value string) bool {
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	expr = strings.ReplaceAll(expr, `\?`, ".")
+
+	matched, err := regexp.MatchString("^"+expr+"$", value)
+
+	return err == nil && matched
+}
+
+// tagsMatch reports whether a resource's tags satisfy every -tag filter
+func tagsMatch(tags map[string]string) bool {
+	for key, patterns := range Config.tags {
+		value, ok := tags[key]
+		if !ok {
+			return false
+		}
+
+		matched := false
+
+		for _, pattern := range patterns {
+			if globMatch(pattern, value) {
+				matched = true
+
+				break
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// filterVpcs drops any vpc not requested with -vpc. Live fetches are already
+// filtered by AWS, this covers snapshots and calls that cannot filter by vpc.
+func filterVpcs(vpcs map[string]*VPC) {
+	if len(Config.vpcIDs) == 0 {
+		return
+	}
+
+	requested := make(map[string]bool)
+	for _, vpcID := range Config.vpcIDs {
+		requested[vpcID] = true
+	}
+
+	for vpcID := range vpcs {
+		if !requested[vpcID] {
+			delete(vpcs, vpcID)
+		}
+	}
+}
+
+// filterTags narrows the listing down to the vpcs, subnets, instances and
+// network interfaces that match the -tag filters. Vpcs and subnets that do
+// not match are still shown when something inside them does. AWS already
+// filters instances on live fetches, the rest can only be filtered here.
+func filterTags(vpcs map[string]*VPC) {
+	if len(Config.tags) == 0 {
+		return
+	}
+
+	for vpcID, vpc := range vpcs {
+		for subnetID, subnet := range vpc.Subnets {
+			if !filterSubnetTags(subnet) {
+				delete(vpc.Subnets, subnetID)
+			}
+		}
+
+		if !tagsMatch(vpc.Tags) && len(vpc.Subnets) == 0 {
+			delete(vpcs, vpcID)
+		}
+	}
+}
+
+// filterSubnetTags drops the instances and standalone network interfaces of
+// a subnet that do not match, and reports whether the subnet is left with
+// anything to show. A matching instance keeps all of its interfaces, which
+// are rarely tagged themselves.
+func filterSubnetTags(subnet *Subnet) bool {
+	for instanceID, instance := range subnet.Instances {
+		if !tagsMatch(instance.Tags) {
+			delete(subnet.Instances, instanceID)
+		}
+	}
+
+	for ifaceID, iface := range subnet.ENIs {
+		if !tagsMatch(iface.Tags) {
+			delete(subnet.ENIs, ifaceID)
+		}
+	}
+
+	return tagsMatch(subnet.Tags) || len(subnet.Instances) > 0 || len(subnet.ENIs) > 0
+}
+
+// filterElasticIPs drops unassociated elastic ips when only some vpcs were
+// requested, since they belong to none, and those not matching -tag.
+func filterElasticIPs(eips []*ElasticIP) []*ElasticIP {
+	if len(Config.vpcIDs) > 0 {
+		return []*ElasticIP{}
+	}
+
+	filtered := []*ElasticIP{}
+
+	for _, eip := range eips {
+		if tagsMatch(eip.Tags) {
+			filtered = append(filtered, eip)
+		}
+	}
+
+	return filtered
+}
@@ -4,14 +4,21 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io/fs"
+	"net/url"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/stigian/lsvpc/awsfetch"
 
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/smithy-go"
 )
 
 type lsvpcConfig struct {
@@ -24,118 +31,454 @@ type lsvpcConfig struct {
 	Verbose        bool
 	HideIP         bool
 	Truncate       bool
+	savePath       string
+	loadPath       string
+	vpcIDs         listFlag
+	tags           tagFlag
+	maxConcurrency int
+	maxRetries     int
+	timeout        time.Duration
+	endpointURL    string
+	roleARNs       listFlag
+	accountsFile   string
+	org            bool
+	orgRole        string
+	profiles       listFlag
+	partition      string
+	fips           bool
+	dualStack      bool
+	cacheTTL       time.Duration
+	watch          time.Duration
+	diffAgainst    string
+	stream         bool
+	routes         bool
 }
 
 var Config lsvpcConfig
 
-func populateVPC(region string) (map[string]*VPC, error) {
-	ctx := context.Background()
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
-	if err != nil {
-		return map[string]*VPC{}, err
+const (
+	defaultMaxConcurrency = 20
+	defaultMaxRetries     = 5
+)
+
+// listFlag collects values from a flag that can be repeated, or given
+// several comma separated values at once.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
 	}
 
-	vpcs := make(map[string]*VPC)
-	fetch := awsfetch.New(cfg)
+	return nil
+}
 
-	received, err := fetch.GetAll(ctx)
+// fetchRegion queries AWS for all of the raw data lsvpc needs in a region.
+// Individual calls failing is not an error here, those are recorded in the
+// results and reported alongside whatever could be fetched.
+func fetchRegion(
+	ctx context.Context,
+	region string,
+	optFns ...func(*config.LoadOptions) error,
+) (*awsfetch.AWSFetch, error) {
+	cfg, err := loadConfig(ctx, append(optFns, config.WithRegion(region))...)
 	if err != nil {
-		return map[string]*VPC{}, err
+		return nil, err
+	}
+
+	opts := []func(*awsfetch.Options){
+		awsfetch.WithVpcIDs(Config.vpcIDs...),
+		awsfetch.WithTags(Config.tags),
+		awsfetch.WithResources(neededResources()...),
 	}
 
+	if cache := newCache(ctx, cfg); cache != nil {
+		opts = append(opts, awsfetch.WithCache(cache))
+	}
+
+	fetch := awsfetch.New(awsfetch.NewClient(cfg), opts...)
+	received, _ := fetch.GetAll(ctx) // errors are retained in received
+
+	return received, nil
+}
+
+// neededResources returns the resources the chosen output actually displays.
+// Text output only shows volumes, security groups and network acls in verbose mode. Json
+// and snapshots, which may later be displayed either way, and diffs, which
+// compare against such snapshots, get everything.
+func neededResources() []string {
+	if Config.jsonOutput || Config.Verbose || Config.savePath != "" || Config.diffAgainst != "" {
+		return awsfetch.Resources()
+	}
+
+	needed := []string{}
+
+	for _, name := range awsfetch.Resources() {
+		if name == awsfetch.Volumes.Name || name == awsfetch.SecurityGroups.Name || name == awsfetch.NetworkACLs.Name {
+			continue
+		}
+
+		needed = append(needed, name)
+	}
+
+	return needed
+}
+
+func newRegionData(received *awsfetch.AWSFetch) RegionData {
+	errs := fetchErrors(received)
+
+	return RegionData{
+		VPCs:       populateVPC(received),
+		ElasticIPs: unassociatedElasticIPs(awsfetch.Addresses.From(received).Data),
+		Fetch:      received,
+		Errors:     errs,
+		Err:        regionFailure(errs),
+	}
+}
+
+// regionFailure decides whether a region failed as a whole. Without its vpcs
+// there is nothing to display, which is what happens when a region is not
+// enabled, denied by an SCP or throttled into the ground.
+func regionFailure(errs []*FetchError) error {
+	for _, fetchErr := range errs {
+		if fetchErr.Resource == awsfetch.Vpcs.Name {
+			return errors.New(fetchErr.Error)
+		}
+	}
+
+	return nil
+}
+
+// regionsFailed reports whether any region failed, which makes lsvpc exit nonzero
+func regionsFailed(fullData map[string]RegionData) bool {
+	for _, regionData := range fullData {
+		if regionData.Err != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fetchErrors converts failed calls into something displayable, preferring
+// the api error code and message over the full sdk error chain.
+func fetchErrors(received *awsfetch.AWSFetch) []*FetchError {
+	errs := []*FetchError{}
+
+	for _, resErr := range received.Errors() {
+		errs = append(errs, &FetchError{
+			Resource: resErr.Resource,
+			Error:    describeError(resErr.Err),
+		})
+	}
+
+	return errs
+}
+
+// describeError shortens an sdk error for display. Calls cut off by -timeout
+// or an interrupt are marked as such rather than showing the full error chain.
+func describeError(err error) string {
+	var apiErr smithy.APIError
+
+	// Each of several joined failures is described on its own
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		msgs := []string{}
+		for _, e := range joined.Unwrap() {
+			msgs = append(msgs, describeError(e))
+		}
+
+		return strings.Join(msgs, "; ")
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timed out"
+	case errors.Is(err, context.Canceled):
+		return "cancelled"
+	case errors.As(err, &apiErr):
+		return fmt.Sprintf("%v: %v", apiErr.ErrorCode(), apiErr.ErrorMessage())
+	}
+
+	return err.Error()
+}
+
+// populateVPC builds the vpc model out of raw fetch results, whether they
+// came from AWS or a saved snapshot.
+func populateVPC(received *awsfetch.AWSFetch) map[string]*VPC {
+	vpcs := make(map[string]*VPC)
+	prefixLists := newPrefixLists(awsfetch.PrefixLists.From(received).Data)
+
 	/* These functions must be executed in a specific order here, or else the mappings will fail. */
-	mapVpcs(vpcs, received.Vpcs.Vpcs)
-	mapSubnets(vpcs, received.Subnets.Subnets)
-	mapInstances(vpcs, received.Instances.Instances)
-	mapInstanceStatuses(vpcs, received.InstanceStatuses.InstanceStatuses)
-	mapVolumes(vpcs, received.Volumes.Volumes)
-	mapNatGateways(vpcs, received.NatGateways.NatGateways)
-	mapRouteTables(vpcs, received.RouteTables.RouteTables)
-	mapInternetGateways(vpcs, received.InternetGateways.InternetGateways)
-	mapEgressOnlyInternetGateways(vpcs, received.EOInternetGateways.EOInternetGateways)
-	mapVPNGateways(vpcs, received.VPNGateways.VPNGateways)
-	mapTransitGatewayVpcAttachments(vpcs, received.TransiGateways.TransitGateways, received.Identity.Identity)
-	mapVpcPeeringConnections(vpcs, received.PeeringConnections.PeeringConnections)
-	mapVpcEndpoints(vpcs, received.VPCEndpoints.VPCEndpoints)
-	mapNetworkInterfaces(vpcs, received.NetworkInterfaces.NetworkInterfaces)
-	mapSecurityGroups(vpcs, received.SecurityGroups.SecurityGroups)
-
-	return vpcs, nil
-}
-
-func getRegionData(region string, out chan RegionData) {
+	mapVpcs(vpcs, awsfetch.Vpcs.From(received).Data)
+	mapSubnets(vpcs, awsfetch.Subnets.From(received).Data)
+	mapInstances(vpcs, awsfetch.Instances.From(received).Data)
+	mapInstanceStatuses(vpcs, awsfetch.InstanceStatuses.From(received).Data)
+	mapVolumes(vpcs, awsfetch.Volumes.From(received).Data)
+	mapNatGateways(vpcs, awsfetch.NatGateways.From(received).Data)
+	mapRouteTables(vpcs, awsfetch.RouteTables.From(received).Data, prefixLists)
+	mapNetworkACLs(vpcs, awsfetch.NetworkACLs.From(received).Data)
+	mapInternetGateways(vpcs, awsfetch.InternetGateways.From(received).Data)
+	mapEgressOnlyInternetGateways(vpcs, awsfetch.EgressOnlyInternetGateways.From(received).Data)
+	mapVPNGateways(vpcs, awsfetch.VPNGateways.From(received).Data)
+	mapTransitGatewayVpcAttachments(vpcs, awsfetch.TransitGatewayAttachments.From(received).Data, awsfetch.Identity.From(received).Data)
+	mapVpcPeeringConnections(vpcs, awsfetch.PeeringConnections.From(received).Data)
+	mapVpcEndpoints(vpcs, awsfetch.VPCEndpoints.From(received).Data)
+	mapNetworkInterfaces(vpcs, awsfetch.NetworkInterfaces.From(received).Data)
+	mapAddresses(vpcs, awsfetch.Addresses.From(received).Data)
+	mapSecurityGroups(vpcs, awsfetch.SecurityGroups.From(received).Data, prefixLists)
+
+	filterVpcs(vpcs)
+	filterTags(vpcs)
+
+	return vpcs
+}
+
+func getRegionData(ctx context.Context, region string, out chan RegionData, optFns ...func(*config.LoadOptions) error) {
 	defer close(out)
 
-	vpcs, err := populateVPC(region)
+	out <- getRegion(ctx, region, optFns...)
+}
+
+func getRegion(ctx context.Context, region string, optFns ...func(*config.LoadOptions) error) RegionData {
+	received, err := fetchRegion(ctx, region, optFns...)
 	if err != nil {
-		out <- RegionData{}
-	} else {
-		out <- RegionData{
-			VPCs: vpcs,
-		}
+		return RegionData{Err: errors.New(describeError(err))}
 	}
+
+	return newRegionData(received)
 }
 
-func doSpecificRegion() {
-	region := Config.regionOverride
+func printRegion(region string, regionData RegionData) {
+	if Config.jsonOutput {
+		// The region object carries its errors alongside whatever was fetched
+		printRegionJSON(sortRegionData(map[string]RegionData{region: regionData})[0])
 
-	vpcs, err := populateVPC(region)
-	if err != nil {
 		return
 	}
 
+	if regionData.Err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to fetch region: %v\n", regionData.Err)
+
+		return
+	}
+
+	printFetchErrors(os.Stdout, regionData.Errors)
+	printListing(sortVPCs(regionData.VPCs))
+	printElasticIPs(sortElasticIPs(regionData.ElasticIPs))
+}
+
+func printRegions(fullData map[string]RegionData) {
+	regionDataSorted := sortRegionData(fullData)
+
 	if Config.jsonOutput {
-		printVPCsJSON(sortVPCs(vpcs))
+		printRegionsJSON(regionDataSorted)
 	} else {
-		printVPCs(sortVPCs(vpcs))
+		for _, region := range regionDataSorted {
+			printRegionSection(region)
+		}
+	}
+}
+
+// printRegionSection prints a region under a header naming it
+func printRegionSection(region *RegionDataSorted) {
+	fmt.Printf("===%v===\n", region.Region)
+
+	if region.Error != "" {
+		printRegionFailure(region.Error)
+
+		return
 	}
+
+	printFetchErrors(os.Stdout, region.Errors)
+	printListing(region.VPCs)
+	printElasticIPs(region.ElasticIPs)
 }
 
-func doAllRegions() {
-	regions := getRegions()
+// fetchRegions fetches the given regions in parallel
+func fetchRegions(ctx context.Context, regions []string, optFns ...func(*config.LoadOptions) error) map[string]RegionData {
 	fullData := make(map[string]RegionData)
 	channels := make(map[string]chan RegionData)
 
 	for _, region := range regions {
 		channels[region] = make(chan RegionData)
-		go getRegionData(region, channels[region])
+		go getRegionData(ctx, region, channels[region], optFns...)
 	}
 
 	for _, region := range regions {
 		fullData[region] = <-channels[region]
 	}
 
-	regionDataSorted := sortRegionData(fullData)
+	return fullData
+}
 
-	if Config.jsonOutput {
-		printRegionsJSON(regionDataSorted)
-	} else {
-		for _, region := range regionDataSorted {
-			fmt.Printf("===%v===\n", region.Region)
-			printVPCs(region.VPCs)
+// regionResult is a region fetched by streamRegions
+type regionResult struct {
+	region string
+	data   RegionData
+}
+
+// streamRegions fetches the given regions in parallel, delivering each as
+// soon as it completes rather than in any particular order.
+func streamRegions(ctx context.Context, regions []string) <-chan regionResult {
+	results := make(chan regionResult)
+
+	for _, region := range regions {
+		go func() {
+			results <- regionResult{region: region, data: getRegion(ctx, region)}
+		}()
+	}
+
+	return results
+}
+
+// doStreamRegions is doAllRegions for -stream, printing each region as soon
+// as it has been fetched. Json output becomes one region object per line.
+func doStreamRegions(ctx context.Context) int {
+	regions, err := getRegions(ctx)
+	if err != nil {
+		fmt.Printf("Could not get regions: %v\n", describeError(err))
+		return 1
+	}
+
+	fullData := make(map[string]RegionData)
+	results := streamRegions(ctx, regions)
+
+	for range regions {
+		result := <-results
+		fullData[result.region] = result.data
+
+		regionSorted := sortRegionData(map[string]RegionData{result.region: result.data})[0]
+
+		if Config.jsonOutput {
+			printRegionJSONLine(regionSorted)
+		} else {
+			printRegionSection(regionSorted)
 		}
 	}
+
+	saveRegions(fullData)
+
+	if regionsFailed(fullData) {
+		return 1
+	}
+
+	return 0
+}
+
+func doSpecificRegion(ctx context.Context) int {
+	region := Config.regionOverride
+
+	regionData := getRegion(ctx, region)
+	saveRegions(map[string]RegionData{region: regionData})
+
+	if Config.diffAgainst != "" {
+		return diffAgainst([]*AccountData{{Regions: map[string]RegionData{region: regionData}}})
+	}
+
+	printRegion(region, regionData)
+
+	if regionData.Err != nil {
+		return 1
+	}
+
+	return 0
 }
 
-func doDefaultRegion() {
-	ctx := context.Background()
-	cfg, err := config.LoadDefaultConfig(ctx)
+func doAllRegions(ctx context.Context) int {
+	regions, err := getRegions(ctx)
+	if err != nil {
+		fmt.Printf("Could not get regions: %v\n", describeError(err))
+		return 1
+	}
+
+	fullData := fetchRegions(ctx, regions)
+
+	saveRegions(fullData)
+
+	if Config.diffAgainst != "" {
+		return diffAgainst([]*AccountData{{Regions: fullData}})
+	}
+
+	printRegions(fullData)
+
+	if regionsFailed(fullData) {
+		return 1
+	}
+
+	return 0
+}
+
+func doDefaultRegion(ctx context.Context) int {
+	cfg, err := loadConfig(ctx)
 	if err != nil {
 		panic(fmt.Sprintf("failed to load config: %v", err.Error()))
 	}
 
 	currentRegion := cfg.Region
 
-	vpcs, err := populateVPC(currentRegion)
+	regionData := getRegion(ctx, currentRegion)
+	saveRegions(map[string]RegionData{currentRegion: regionData})
+
+	if Config.diffAgainst != "" {
+		return diffAgainst([]*AccountData{{Regions: map[string]RegionData{currentRegion: regionData}}})
+	}
+
+	printRegion(currentRegion, regionData)
+
+	if regionData.Err != nil {
+		return 1
+	}
+
+	return 0
+}
+
+func doLoad() int {
+	snapshot, err := readSnapshot(Config.loadPath)
 	if err != nil {
-		panic(fmt.Sprintf("populateVPC failed: %v", err.Error()))
+		fmt.Printf("Failed to load snapshot: %v\n", err)
+		return 1
 	}
 
-	if Config.jsonOutput {
-		printVPCsJSON(sortVPCs(vpcs))
+	accounts := snapshotAccounts(snapshot)
+
+	if len(accounts) == 0 {
+		fmt.Printf("No region data found in snapshot '%v'\n", Config.loadPath)
+		return 1
+	}
+
+	// Snapshots of several accounts are grouped by account like the live output
+	if len(accounts) > 1 || accounts[0].ID != "" || accounts[0].Err != nil {
+		printAccounts(accounts)
+
+		if accountsFailed(accounts) {
+			return 1
+		}
+
+		return 0
+	}
+
+	fullData := accounts[0].Regions
+
+	// A snapshot of a single region is printed as if that region had been queried directly
+	if len(fullData) == 1 && !Config.allRegions {
+		for region, regionData := range fullData {
+			printRegion(region, regionData)
+		}
 	} else {
-		printVPCs(sortVPCs(vpcs))
+		printRegions(fullData)
+	}
+
+	if regionsFailed(fullData) {
+		return 1
 	}
+
+	return 0
 }
 
 func init() {
@@ -150,6 +493,27 @@ func init() {
 	flag.BoolVar(&Config.HideIP, "n", false, "do not display IP addresses and CIDRs (does not affect json output)")
 	flag.BoolVar(&Config.Verbose, "v", false, "output verbose information about assets in vpc")
 	flag.BoolVar(&Config.Truncate, "t", false, "truncate nametags")
+	flag.BoolVar(&Config.routes, "routes", false, "List every route of each subnet's route table instead of the resources in the subnet")
+	flag.Var(&Config.vpcIDs, "vpc", "Only fetch and display the given vpc ids, comma separated or repeated")
+	flag.Var(&Config.tags, "tag", "Only display vpcs, subnets, instances and interfaces tagged Key=Value, repeatable, values may use * and ? wildcards")
+	flag.IntVar(&Config.maxConcurrency, "max-concurrency", defaultMaxConcurrency, "Maximum number of AWS requests in flight at once, across all regions")
+	flag.IntVar(&Config.maxRetries, "max-retries", defaultMaxRetries, "Maximum number of retries for a failed or throttled AWS request")
+	flag.DurationVar(&Config.timeout, "timeout", 0, "Give up on requests still running after this long, e.g. 30s (default: no timeout)")
+	flag.StringVar(&Config.endpointURL, "endpoint-url", os.Getenv("LSVPC_ENDPOINT_URL"), "Send AWS requests to this url instead, e.g. a local emulator (env: LSVPC_ENDPOINT_URL)")
+	flag.Var(&Config.roleARNs, "role-arn", "Assume this role and list the vpcs of its account, may be repeated or comma separated")
+	flag.StringVar(&Config.accountsFile, "accounts-file", "", "Assume each role arn listed in this file, one per line")
+	flag.Var(&Config.profiles, "profile", "Use this shared config profile, several comma separated or repeated profiles are listed side by side")
+	flag.StringVar(&Config.partition, "partition", "", "AWS partition: aws, aws-us-gov, aws-cn, aws-iso or aws-iso-b (default: detected from credentials)")
+	flag.BoolVar(&Config.org, "org", false, "List the vpcs of every active account in the organization")
+	flag.StringVar(&Config.orgRole, "org-role", defaultOrgRole, "Name of the role -org assumes into each member account")
+	flag.BoolVar(&Config.fips, "fips", false, "Only use FIPS 140-2 validated AWS endpoints")
+	flag.BoolVar(&Config.dualStack, "dualstack", false, "Use dual-stack (IPv4 and IPv6) AWS endpoints")
+	flag.DurationVar(&Config.cacheTTL, "cache-ttl", defaultCacheTTL, "Cache fetched vpc layouts for this long, volumes and interfaces for a fifth of it (default off)")
+	flag.DurationVar(&Config.watch, "watch", 0, "Redraw the listing every interval, e.g. 10s, marking what changed since the last refresh")
+	flag.BoolVar(&Config.stream, "stream", false, "With -a, print each region as soon as it is fetched instead of in sorted order, as ndjson with -j")
+	flag.StringVar(&Config.savePath, "save", "", "Save the raw data fetched from AWS to a snapshot file")
+	flag.StringVar(&Config.loadPath, "load", "", "Display a snapshot file created with -save instead of querying AWS")
+	flag.StringVar(&Config.diffAgainst, "diff-against", "", "Compare the live listing against a snapshot file, printing what changed instead")
 }
 
 func stdoutIsPipe() bool {
@@ -163,9 +527,9 @@ func stdoutIsPipe() bool {
 	return mode&fs.ModeNamedPipe != 0
 }
 
-func credentialsLoaded() bool {
-	ctx := context.Background()
-	cfg, err := config.LoadDefaultConfig(ctx)
+func credentialsLoaded(ctx context.Context) bool {
+	// Credentials don't depend on the region, which loadConfig may reject
+	cfg, err := loadPartitionlessConfig(ctx)
 	if err != nil {
 		return false
 	}
@@ -179,18 +543,15 @@ func credentialsLoaded() bool {
 		return false
 	}
 
-	if cfg.Region == "" {
-		// No default region in profile/credentials, check that AWS_DEFAULT_REGION exists
-		if os.Getenv("AWS_DEFAULT_REGION") == "" {
-			os.Setenv("AWS_DEFAULT_REGION", "us-east-1")
-		}
-	}
-
 	return true
 }
 
-func validateRegion(region string) bool {
-	regions := getRegions()
+func validateRegion(ctx context.Context, region string) (bool, error) {
+	regions, err := getRegions(ctx)
+	if err != nil {
+		return false, err
+	}
+
 	isValid := false
 
 	for _, reg := range regions {
@@ -199,11 +560,23 @@ func validateRegion(region string) bool {
 		}
 	}
 
-	return isValid
+	return isValid, nil
 }
 
 func main() {
-	flag.Parse()
+	os.Exit(run())
+}
+
+// run does everything main would, returning the exit code instead of exiting
+// so that its deferred calls still run.
+func run() int {
+	// `lsvpc diff` compares two snapshots, taking the same flags as a listing
+	diffSnapshots := len(os.Args) > 1 && os.Args[1] == "diff"
+	if diffSnapshots {
+		_ = flag.CommandLine.Parse(os.Args[2:]) // exits on error
+	} else {
+		flag.Parse()
+	}
 
 	if stdoutIsPipe() {
 		if !Config.Color {
@@ -211,24 +584,157 @@ func main() {
 		}
 	}
 
-	if !credentialsLoaded() {
+	initColor()
+
+	if diffSnapshots {
+		return doDiff(flag.Args())
+	}
+
+	roles, err := roleARNs()
+	if err != nil {
+		fmt.Printf("Failed to read accounts: %v\n", err)
+		return 1
+	}
+
+	if Config.watch < 0 {
+		fmt.Println("-watch cannot be negative")
+		return 1
+	}
+
+	if conflicts := watchConflicts(roles); Config.watch > 0 && conflicts != "" {
+		fmt.Printf("-watch cannot be combined with %v\n", conflicts)
+		return 1
+	}
+
+	if Config.diffAgainst != "" && Config.loadPath != "" {
+		fmt.Println("-diff-against cannot be combined with -load, use lsvpc diff to compare two snapshots")
+		return 1
+	}
+
+	if Config.stream && (!Config.allRegions || Config.loadPath != "" || Config.diffAgainst != "" ||
+		Config.org || len(roles) > 0 || len(Config.profiles) > 1) {
+		fmt.Println("-stream requires -a, and cannot be combined with -load, -diff-against, -org, -role-arn, -accounts-file or several -profile")
+		return 1
+	}
+
+	if Config.loadPath != "" {
+		return doLoad()
+	}
+
+	if Config.maxConcurrency < 1 || Config.maxRetries < 0 {
+		fmt.Println("-max-concurrency must be at least 1, and -max-retries cannot be negative")
+		return 1
+	}
+
+	if Config.endpointURL != "" && (Config.fips || Config.dualStack) {
+		fmt.Println("-endpoint-url cannot be combined with -fips or -dualstack")
+		return 1
+	}
+
+	if Config.endpointURL != "" {
+		if u, err := url.Parse(Config.endpointURL); err != nil || u.Scheme == "" || u.Host == "" {
+			fmt.Printf("Endpoint url: '%v' is not valid, expected e.g. http://localhost:4566\n", Config.endpointURL)
+			return 1
+		}
+	}
+
+	if Config.org && len(roles) > 0 {
+		fmt.Println("-org cannot be combined with -role-arn or -accounts-file")
+		return 1
+	}
+
+	if Config.partition != "" {
+		region, ok := partitionRegions[Config.partition]
+		if !ok {
+			fmt.Printf("Partition: '%v' is not valid\n", Config.partition)
+			return 1
+		}
+
+		fallbackRegion = region
+	}
+
+	if len(Config.profiles) > 1 && (Config.org || len(roles) > 0) {
+		fmt.Println("Several -profile cannot be combined with -org, -role-arn or -accounts-file")
+		return 1
+	}
+
+	apiLimiter = awsfetch.NewLimiter(Config.maxConcurrency)
+
+	// Everything below shares this context, an interrupt or -timeout cancels
+	// whatever is still in flight and what has completed is still displayed.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// -watch applies the timeout to each refresh instead
+	if Config.timeout > 0 && Config.watch == 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, Config.timeout)
+		defer cancel()
+	}
+
+	go func() {
+		// Restore default signal handling, so a second interrupt exits immediately
+		<-ctx.Done()
+		stop()
+	}()
+
+	// Each of several profiles is checked, and its partition detected, as it
+	// is fetched. One that cannot be loaded is reported like an account that
+	// cannot be accessed.
+	if len(Config.profiles) > 1 {
+		return doAccounts(ctx, profileAccounts(Config.profiles))
+	}
+
+	if !credentialsLoaded(ctx) {
 		fmt.Println("Failed to load aws credentials.")
-		fmt.Println("Please set your AWS_PROFILE environment variable to a valid profile.")
-		os.Exit(1)
+		fmt.Println("Please set your AWS_PROFILE environment variable, or -profile, to a valid profile.")
+		return 1
+	}
+
+	partition, err := detectPartition(ctx)
+	if err != nil {
+		fmt.Printf("Could not determine the AWS partition, try -partition: %v\n", describeError(err))
+		return 1
+	}
+
+	fallbackRegion = partitionRegions[partition]
+
+	if _, err := loadConfig(ctx); err != nil {
+		fmt.Printf("Failed to load config: %v\n", err)
+		return 1
 	}
 
 	// exit if region override is not valid
-	if Config.regionOverride != "" && !validateRegion(Config.regionOverride) {
-		fmt.Printf("Region: '%v' is not valid\n", Config.regionOverride)
-		os.Exit(1)
+	if Config.regionOverride != "" {
+		valid, err := validateRegion(ctx, Config.regionOverride)
+		if err != nil {
+			fmt.Printf("Could not get regions: %v\n", describeError(err))
+			return 1
+		}
+
+		if !valid {
+			fmt.Printf("Region: '%v' is not valid\n", Config.regionOverride)
+			return 1
+		}
 	}
 
 	switch {
+	case Config.watch > 0:
+		doWatch(ctx)
+
+		return 0
+	case Config.org:
+		return doOrg(ctx)
+	case len(roles) > 0:
+		return doAccounts(ctx, roleAccounts(roles))
+	case Config.allRegions && Config.stream:
+		return doStreamRegions(ctx)
 	case Config.allRegions:
-		doAllRegions()
+		return doAllRegions(ctx)
 	case Config.regionOverride != "":
-		doSpecificRegion()
+		return doSpecificRegion(ctx)
 	default:
-		doDefaultRegion()
+		return doDefaultRegion(ctx)
 	}
 }
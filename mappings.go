@@ -2,11 +2,14 @@
 package main
 
 import (
-	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
+
+	"github.com/stigian/lsvpc/awsfetch"
 )
 
 func getNameTag(tags []types.Tag) string {
@@ -21,6 +24,34 @@ func getNameTag(tags []types.Tag) string {
 	return name
 }
 
+func getTags(tags []types.Tag) map[string]string {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	tagMap := make(map[string]string)
+
+	for _, tag := range tags {
+		tagMap[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+
+	return tagMap
+}
+
+// lookupSubnet returns a subnet only if both it and its vpc have been mapped.
+// Resources can refer to vpcs and subnets that are absent from the model when
+// a fetch partially failed.
+func lookupSubnet(vpcs map[string]*VPC, vpcID, subnetID string) (*Subnet, bool) {
+	vpc, ok := vpcs[vpcID]
+	if !ok {
+		return nil, false
+	}
+
+	subnet, ok := vpc.Subnets[subnetID]
+
+	return subnet, ok
+}
+
 func mapVpcs(vpcs map[string]*VPC, vpcData []types.Vpc) {
 	for _, v := range vpcData {
 		var v6cidr string
@@ -40,6 +71,7 @@ func mapVpcs(vpcs map[string]*VPC, vpcData []types.Vpc) {
 				CidrBlock:     aws.ToString(v.CidrBlock),
 				IPv6CidrBlock: v6cidr,
 				Name:          getNameTag(v.Tags),
+				Tags:          getTags(v.Tags),
 			},
 			RawVPC:  v,
 			Subnets: make(map[string]*Subnet),
@@ -52,13 +84,19 @@ func mapSubnets(vpcs map[string]*VPC, subnets []types.Subnet) {
 	for _, v := range subnets {
 		isPublic := aws.ToBool(v.MapCustomerOwnedIpOnLaunch) || aws.ToBool(v.MapPublicIpOnLaunch)
 
-		vpcs[aws.ToString(v.VpcId)].Subnets[aws.ToString(v.SubnetId)] = &Subnet{
+		vpc, ok := vpcs[aws.ToString(v.VpcId)]
+		if !ok {
+			continue
+		}
+
+		vpc.Subnets[aws.ToString(v.SubnetId)] = &Subnet{
 			SubnetData: SubnetData{
 				ID:                 aws.ToString(v.SubnetId),
 				CidrBlock:          aws.ToString(v.CidrBlock),
 				AvailabilityZone:   aws.ToString(v.AvailabilityZone),
 				AvailabilityZoneID: aws.ToString(v.AvailabilityZoneId),
 				Name:               getNameTag(v.Tags),
+				Tags:               getTags(v.Tags),
 				Public:             isPublic,
 			},
 			RawSubnet:          v,
@@ -80,8 +118,8 @@ func mapInstances(vpcs map[string]*VPC, reservations []types.Reservation) {
 				subnetID := aws.ToString(instance.SubnetId)
 				instanceID := aws.ToString(instance.InstanceId)
 
-				if vpcID != "" && subnetID != "" && instanceID != "" {
-					vpcs[vpcID].Subnets[subnetID].Instances[instanceID] = &Instance{
+				if subnet, ok := lookupSubnet(vpcs, vpcID, subnetID); ok && instanceID != "" {
+					subnet.Instances[instanceID] = &Instance{
 						InstanceData: InstanceData{
 							ID:           aws.ToString(instance.InstanceId),
 							Type:         string(instance.InstanceType),
@@ -91,6 +129,7 @@ func mapInstances(vpcs map[string]*VPC, reservations []types.Reservation) {
 							PublicIP:     aws.ToString(instance.PublicIpAddress),
 							PrivateIP:    aws.ToString(instance.PrivateIpAddress),
 							Name:         getNameTag(instance.Tags),
+							Tags:         getTags(instance.Tags),
 							PlatformName: aws.ToString(instance.PlatformDetails),
 							PlatformType: string(instance.Platform),
 						},
@@ -159,11 +198,22 @@ func mapNatGateways(vpcs map[string]*VPC, natGateways []types.NatGateway) {
 			continue
 		}
 
-		vpcs[aws.ToString(gateway.VpcId)].Subnets[aws.ToString(gateway.SubnetId)].NatGateways[aws.ToString(gateway.NatGatewayId)] = &NatGateway{
+		subnet, ok := lookupSubnet(vpcs, aws.ToString(gateway.VpcId), aws.ToString(gateway.SubnetId))
+		if !ok {
+			continue
+		}
+
+		var privateIP, publicIP string
+		if len(gateway.NatGatewayAddresses) > 0 {
+			privateIP = aws.ToString(gateway.NatGatewayAddresses[0].PrivateIp)
+			publicIP = aws.ToString(gateway.NatGatewayAddresses[0].PublicIp)
+		}
+
+		subnet.NatGateways[aws.ToString(gateway.NatGatewayId)] = &NatGateway{
 			NatGatewayData: NatGatewayData{
 				ID:            aws.ToString(gateway.NatGatewayId),
-				PrivateIP:     aws.ToString(gateway.NatGatewayAddresses[0].PrivateIp),
-				PublicIP:      aws.ToString(gateway.NatGatewayAddresses[0].PublicIp),
+				PrivateIP:     privateIP,
+				PublicIP:      publicIP,
 				State:         string(gateway.State),
 				Type:          string(gateway.ConnectivityType),
 				Name:          getNameTag(gateway.Tags),
@@ -174,58 +224,235 @@ func mapNatGateways(vpcs map[string]*VPC, natGateways []types.NatGateway) {
 	}
 }
 
-func getDefaultRoute(rtb types.RouteTable) string {
+// routeTarget returns the id of whatever a route sends traffic to
+func routeTarget(route types.Route) string {
+	if dest := aws.ToString(route.CarrierGatewayId); dest != "" {
+		return dest
+	}
+
+	if dest := aws.ToString(route.EgressOnlyInternetGatewayId); dest != "" {
+		return dest
+	}
+
+	if dest := aws.ToString(route.GatewayId); dest != "" {
+		return dest
+	}
+
+	if dest := aws.ToString(route.InstanceId); dest != "" {
+		return dest
+	}
+
+	if dest := aws.ToString(route.LocalGatewayId); dest != "" {
+		return dest
+	}
+
+	if dest := aws.ToString(route.NatGatewayId); dest != "" {
+		return dest
+	}
+
+	if dest := aws.ToString(route.NetworkInterfaceId); dest != "" {
+		return dest
+	}
+
+	if dest := aws.ToString(route.TransitGatewayId); dest != "" {
+		return dest
+	}
+
+	if dest := aws.ToString(route.VpcPeeringConnectionId); dest != "" {
+		return dest
+	}
+
+	if dest := aws.ToString(route.CoreNetworkArn); dest != "" {
+		return dest
+	}
+
+	return ""
+}
+
+func getDefaultRoute(rtb types.RouteTable, prefixLists map[string]*PrefixList) string {
 	for _, route := range rtb.Routes {
 		if !(aws.ToString(route.DestinationCidrBlock) == "0.0.0.0/0" ||
 			aws.ToString(route.DestinationIpv6CidrBlock) == "::/0") {
 			continue
 		}
 
-		if dest := aws.ToString(route.CarrierGatewayId); dest != "" {
+		if dest := routeTarget(route); dest != "" {
 			return dest
 		}
+	}
 
-		if dest := aws.ToString(route.EgressOnlyInternetGatewayId); dest != "" {
-			return dest
+	// A route to a prefix list holding a default cidr also acts as the
+	// default route, though an explicit default cidr route takes priority
+	for _, route := range rtb.Routes {
+		if route.DestinationPrefixListId == nil {
+			continue
 		}
 
-		if dest := aws.ToString(route.GatewayId); dest != "" {
-			return dest
+		if !coversEverything(lookupPrefixList(prefixLists, aws.ToString(route.DestinationPrefixListId))) {
+			continue
 		}
 
-		if dest := aws.ToString(route.InstanceId); dest != "" {
+		if dest := routeTarget(route); dest != "" {
 			return dest
 		}
+	}
 
-		if dest := aws.ToString(route.LocalGatewayId); dest != "" {
-			return dest
+	return "" // No default route found, which doesn't necessarily mean an error
+}
+
+// coversEverything reports whether a prefix list holds a default cidr
+func coversEverything(prefixList *PrefixList) bool {
+	for _, entry := range prefixList.Entries {
+		if entry.Cidr == "0.0.0.0/0" || entry.Cidr == "::/0" {
+			return true
 		}
+	}
 
-		if dest := aws.ToString(route.NatGatewayId); dest != "" {
-			return dest
+	return false
+}
+
+func newRouteTable(routeTable types.RouteTable, prefixLists map[string]*PrefixList) *RouteTable {
+	routes := []*Route{}
+
+	for _, route := range routeTable.Routes {
+		routeOut := &Route{
+			Target: routeTarget(route),
+			Origin: string(route.Origin),
+			State:  string(route.State),
 		}
 
-		if dest := aws.ToString(route.NetworkInterfaceId); dest != "" {
-			return dest
+		switch {
+		case route.DestinationCidrBlock != nil:
+			routeOut.Destination = aws.ToString(route.DestinationCidrBlock)
+		case route.DestinationIpv6CidrBlock != nil:
+			routeOut.Destination = aws.ToString(route.DestinationIpv6CidrBlock)
+		case route.DestinationPrefixListId != nil:
+			routeOut.Destination = aws.ToString(route.DestinationPrefixListId)
+			routeOut.PrefixList = lookupPrefixList(prefixLists, routeOut.Destination)
 		}
 
-		if dest := aws.ToString(route.TransitGatewayId); dest != "" {
-			return dest
+		routes = append(routes, routeOut)
+	}
+
+	main := false
+	for _, association := range routeTable.Associations {
+		main = main || aws.ToBool(association.Main)
+	}
+
+	return &RouteTable{
+		ID:       aws.ToString(routeTable.RouteTableId),
+		Default:  getDefaultRoute(routeTable, prefixLists),
+		Main:     main,
+		Routes:   routes,
+		RawRoute: routeTable,
+	}
+}
+
+const (
+	subnetPublic      = "public"
+	subnetPrivateNAT  = "private-nat"
+	subnetPrivateEIGW = "private-eigw"
+	subnetPrivateTGW  = "private-tgw"
+	subnetPrivateVGW  = "private-vgw"
+	subnetIsolated    = "isolated"
+)
+
+// classifySubnet works out how a subnet reaches beyond its vpc from its
+// route table. A route to an internet gateway makes it public, otherwise a
+// route through a nat gateway, or a default route through an instance or
+// interface such as a nat instance, makes it private-nat, then a route to
+// an egress-only internet gateway private-eigw, a transit gateway
+// private-tgw and a vpn gateway private-vgw. Blackhole routes are ignored,
+// and a subnet with none of these is isolated
+func classifySubnet(routeTable *RouteTable) string {
+	if routeTable == nil {
+		return "" // route tables weren't fetched, so there is nothing to go on
+	}
+
+	nat, eigw, tgw, vgw := false, false, false, false
+
+	for _, route := range routeTable.Routes {
+		if route.State == "blackhole" {
+			continue
 		}
 
-		if dest := aws.ToString(route.VpcPeeringConnectionId); dest != "" {
-			return dest
+		switch {
+		case strings.HasPrefix(route.Target, "igw-"):
+			return subnetPublic
+		case strings.HasPrefix(route.Target, "nat-"):
+			nat = true
+		case strings.HasPrefix(route.Target, "i-") || strings.HasPrefix(route.Target, "eni-"):
+			nat = nat || isDefaultDestination(route)
+		case strings.HasPrefix(route.Target, "eigw-"):
+			eigw = true
+		case strings.HasPrefix(route.Target, "tgw-"):
+			tgw = true
+		case strings.HasPrefix(route.Target, "vgw-"):
+			vgw = true
 		}
+	}
 
-		if dest := aws.ToString(route.CoreNetworkArn); dest != "" {
-			return dest
+	switch {
+	case nat:
+		return subnetPrivateNAT
+	case eigw:
+		return subnetPrivateEIGW
+	case tgw:
+		return subnetPrivateTGW
+	case vgw:
+		return subnetPrivateVGW
+	default:
+		return subnetIsolated
+	}
+}
+
+// isDefaultDestination reports whether a route covers all addresses
+func isDefaultDestination(route *Route) bool {
+	if route.PrefixList != nil {
+		return coversEverything(route.PrefixList)
+	}
+
+	return route.Destination == "0.0.0.0/0" || route.Destination == "::/0"
+}
+
+func newPrefixLists(lists []awsfetch.PrefixList) map[string]*PrefixList {
+	prefixLists := make(map[string]*PrefixList)
+
+	for _, list := range lists {
+		prefixList := &PrefixList{
+			ID:   aws.ToString(list.PrefixListId),
+			Name: aws.ToString(list.PrefixListName),
 		}
+
+		// nil entries mark a list whose entries could not be read
+		if list.Entries != nil {
+			prefixList.Entries = []*PrefixListEntry{}
+		}
+
+		for _, entry := range list.Entries {
+			prefixList.Entries = append(prefixList.Entries, &PrefixListEntry{
+				Cidr:        aws.ToString(entry.Cidr),
+				Description: aws.ToString(entry.Description),
+			})
+		}
+
+		prefixLists[prefixList.ID] = prefixList
 	}
 
-	return "" // No default route found, which doesn't necessarily mean an error
+	return prefixLists
+}
+
+// lookupPrefixList resolves a referenced prefix list. Lists that could not
+// be fetched are returned by id alone, without a name or entries.
+func lookupPrefixList(prefixLists map[string]*PrefixList, id string) *PrefixList {
+	if prefixList, ok := prefixLists[id]; ok {
+		return prefixList
+	}
+
+	return &PrefixList{ID: id}
 }
 
-func mapRouteTables(vpcs map[string]*VPC, routeTables []types.RouteTable) {
+func mapRouteTables(vpcs map[string]*VPC, routeTables []types.RouteTable, prefixLists map[string]*PrefixList) {
 	// AWS doesn't actually have explicit queryable associations of route
 	// tables to subnets. if no other route tables say they are associated
 	// with a subnet, then that subnet is assumed to be on the default route table.
@@ -237,16 +464,15 @@ func mapRouteTables(vpcs map[string]*VPC, routeTables []types.RouteTable) {
 	//
 	// first pass, associate the default route with everything
 	for _, routeTable := range routeTables {
+		if _, ok := vpcs[aws.ToString(routeTable.VpcId)]; !ok {
+			continue
+		}
+
 		for _, association := range routeTable.Associations {
 			if association.Main != nil && aws.ToBool(association.Main) {
 				for subnetID := range vpcs[aws.ToString(routeTable.VpcId)].Subnets {
 					subnet := vpcs[aws.ToString(routeTable.VpcId)].Subnets[subnetID]
-					defaultRoute := getDefaultRoute(routeTable)
-					subnet.RouteTable = &RouteTable{
-						ID:       aws.ToString(routeTable.RouteTableId),
-						Default:  defaultRoute,
-						RawRoute: routeTable,
-					}
+					subnet.RouteTable = newRouteTable(routeTable, prefixLists)
 					vpcs[aws.ToString(routeTable.VpcId)].Subnets[subnetID] = subnet
 				}
 			}
@@ -263,25 +489,109 @@ func mapRouteTables(vpcs map[string]*VPC, routeTables []types.RouteTable) {
 				continue
 			}
 
-			subnet := vpcs[aws.ToString(routeTable.VpcId)].Subnets[aws.ToString(association.SubnetId)]
-			defaultRoute := getDefaultRoute(routeTable)
-			subnet.RouteTable = &RouteTable{
-				ID:       aws.ToString(routeTable.RouteTableId),
-				Default:  defaultRoute,
-				RawRoute: routeTable,
+			subnet, ok := lookupSubnet(vpcs, aws.ToString(routeTable.VpcId), aws.ToString(association.SubnetId))
+			if !ok {
+				continue
 			}
+
+			subnet.RouteTable = newRouteTable(routeTable, prefixLists)
 			vpcs[aws.ToString(routeTable.VpcId)].Subnets[aws.ToString(association.SubnetId)] = subnet
 		}
 	}
+
+	// last, classify each subnet by the route table it ended up with
+	for _, vpc := range vpcs {
+		for _, subnet := range vpc.Subnets {
+			subnet.Class = classifySubnet(subnet.RouteTable)
+		}
+	}
+}
+
+func mapNetworkACLs(vpcs map[string]*VPC, networkACLs []types.NetworkAcl) {
+	// Like route tables, any subnet without an explicit association falls
+	// back to its vpc's default network acl. Every subnet is normally listed
+	// in some acl's associations, but not if it was created after the acls
+	// were fetched.
+	//
+	// first pass, associate the default acl with everything
+	for _, networkACL := range networkACLs {
+		vpc, ok := vpcs[aws.ToString(networkACL.VpcId)]
+		if !ok || !aws.ToBool(networkACL.IsDefault) {
+			continue
+		}
+
+		acl := newNetworkACL(networkACL)
+		for _, subnet := range vpc.Subnets {
+			subnet.NetworkACL = acl
+		}
+	}
+
+	// second pass, assign each acl to its explicitly associated subnets
+	for _, networkACL := range networkACLs {
+		acl := newNetworkACL(networkACL)
+
+		for _, association := range networkACL.Associations {
+			subnet, ok := lookupSubnet(vpcs, aws.ToString(networkACL.VpcId), aws.ToString(association.SubnetId))
+			if !ok {
+				continue
+			}
+
+			subnet.NetworkACL = acl
+		}
+	}
+}
+
+func newNetworkACL(networkACL types.NetworkAcl) *NetworkACL {
+	acl := &NetworkACL{
+		ID:            aws.ToString(networkACL.NetworkAclId),
+		Name:          getNameTag(networkACL.Tags),
+		IsDefault:     aws.ToBool(networkACL.IsDefault),
+		RawNetworkACL: networkACL,
+		Inbound:       []*NetworkACLRule{},
+		Outbound:      []*NetworkACLRule{},
+	}
+
+	for _, entry := range networkACL.Entries {
+		rule := &NetworkACLRule{
+			RuleNumber:    aws.ToInt32(entry.RuleNumber),
+			Action:        string(entry.RuleAction),
+			Protocol:      aws.ToString(entry.Protocol),
+			CidrBlock:     aws.ToString(entry.CidrBlock),
+			IPv6CidrBlock: aws.ToString(entry.Ipv6CidrBlock),
+		}
+
+		if entry.PortRange != nil {
+			rule.FromPort = aws.ToInt32(entry.PortRange.From)
+			rule.ToPort = aws.ToInt32(entry.PortRange.To)
+		}
+
+		if entry.IcmpTypeCode != nil {
+			rule.ICMPType = aws.ToInt32(entry.IcmpTypeCode.Type)
+			rule.ICMPCode = aws.ToInt32(entry.IcmpTypeCode.Code)
+		}
+
+		if aws.ToBool(entry.Egress) {
+			acl.Outbound = append(acl.Outbound, rule)
+		} else {
+			acl.Inbound = append(acl.Inbound, rule)
+		}
+	}
+
+	// Rules are evaluated lowest number first, the catch-all deny (32767) comes last
+	for _, rules := range [][]*NetworkACLRule{acl.Inbound, acl.Outbound} {
+		sort.SliceStable(rules, func(i, j int) bool {
+			return rules[i].RuleNumber < rules[j].RuleNumber
+		})
+	}
+
+	return acl
 }
 
 func mapInternetGateways(vpcs map[string]*VPC, internetGateways []types.InternetGateway) {
 	for _, igw := range internetGateways {
 		for _, attachment := range igw.Attachments {
-			if vpcID := aws.ToString(attachment.VpcId); vpcID != "" {
-				vpc := vpcs[vpcID]
+			if vpc, ok := vpcs[aws.ToString(attachment.VpcId)]; ok {
 				vpc.Gateways = append(vpc.Gateways, aws.ToString(igw.InternetGatewayId))
-				vpcs[vpcID] = vpc
 			}
 		}
 	}
@@ -290,10 +600,8 @@ func mapInternetGateways(vpcs map[string]*VPC, internetGateways []types.Internet
 func mapEgressOnlyInternetGateways(vpcs map[string]*VPC, eOIGWs []types.EgressOnlyInternetGateway) {
 	for _, eoigw := range eOIGWs {
 		for _, attach := range eoigw.Attachments {
-			if string(attach.State) == "attached" {
-				vpc := vpcs[aws.ToString(attach.VpcId)]
+			if vpc, ok := vpcs[aws.ToString(attach.VpcId)]; ok && string(attach.State) == "attached" {
 				vpc.Gateways = append(vpc.Gateways, aws.ToString(eoigw.EgressOnlyInternetGatewayId))
-				vpcs[aws.ToString(attach.VpcId)] = vpc
 			}
 		}
 	}
@@ -302,10 +610,8 @@ func mapEgressOnlyInternetGateways(vpcs map[string]*VPC, eOIGWs []types.EgressOn
 func mapVPNGateways(vpcs map[string]*VPC, vpnGateways []types.VpnGateway) {
 	for _, vpgw := range vpnGateways {
 		for _, attach := range vpgw.VpcAttachments {
-			if string(attach.State) == "attached" {
-				vpc := vpcs[aws.ToString(attach.VpcId)]
+			if vpc, ok := vpcs[aws.ToString(attach.VpcId)]; ok && string(attach.State) == "attached" {
 				vpc.Gateways = append(vpc.Gateways, aws.ToString(vpgw.VpnGatewayId))
-				vpcs[aws.ToString(attach.VpcId)] = vpc
 			}
 		}
 	}
@@ -313,18 +619,19 @@ func mapVPNGateways(vpcs map[string]*VPC, vpnGateways []types.VpnGateway) {
 
 func mapTransitGatewayVpcAttachments(vpcs map[string]*VPC, transitGatewayVpcAttachments []types.TransitGatewayVpcAttachment, identity *sts.GetCallerIdentityOutput) {
 	for _, tgwatt := range transitGatewayVpcAttachments {
-		// Transit Gateway vpc attachments are reported for external accounts too, need to omit those to fit in this data model
-		if aws.ToString(tgwatt.VpcOwnerId) == aws.ToString(identity.Account) {
-			if vpcID := aws.ToString(tgwatt.VpcId); vpcID != "" {
-				for _, subnet := range tgwatt.SubnetIds {
-					if subnetID := subnet; subnetID != "" {
-						vpcs[vpcID].Subnets[subnetID].TGWs[aws.ToString(tgwatt.TransitGatewayAttachmentId)] = &TGWAttachment{
-							AttachmentID:     aws.ToString(tgwatt.TransitGatewayAttachmentId),
-							TransitGatewayID: aws.ToString(tgwatt.TransitGatewayId),
-							Name:             getNameTag(tgwatt.Tags),
-							RawAttachment:    tgwatt,
-						}
-					}
+		// Transit Gateway vpc attachments are reported for external accounts too, need to omit those to fit in this data model.
+		// Without a caller identity, vpcs that were not mapped are still skipped below.
+		if identity != nil && aws.ToString(tgwatt.VpcOwnerId) != aws.ToString(identity.Account) {
+			continue
+		}
+
+		for _, subnetID := range tgwatt.SubnetIds {
+			if subnet, ok := lookupSubnet(vpcs, aws.ToString(tgwatt.VpcId), subnetID); ok {
+				subnet.TGWs[aws.ToString(tgwatt.TransitGatewayAttachmentId)] = &TGWAttachment{
+					AttachmentID:     aws.ToString(tgwatt.TransitGatewayAttachmentId),
+					TransitGatewayID: aws.ToString(tgwatt.TransitGatewayId),
+					Name:             getNameTag(tgwatt.Tags),
+					RawAttachment:    tgwatt,
 				}
 			}
 		}
@@ -379,6 +686,7 @@ func mapNetworkInterfaces(vpcs map[string]*VPC, networkInterfaces []types.Networ
 				Type:                string(iface.InterfaceType),
 				Description:         aws.ToString(iface.Description),
 				Name:                getNameTag(iface.TagSet),
+				Tags:                getTags(iface.TagSet),
 				SubnetID:            aws.ToString(iface.SubnetId),
 				RawNetworkInterface: iface,
 			},
@@ -440,13 +748,105 @@ func mapNetworkInterfaces(vpcs map[string]*VPC, networkInterfaces []types.Networ
 			continue // The interface is already displayed as a part of the instance, no need to duplicate
 		}
 
-		vpcs[aws.ToString(iface.VpcId)].
-			Subnets[aws.ToString(iface.SubnetId)].
-			ENIs[aws.ToString(iface.NetworkInterfaceId)] = &ifaceIn
+		if subnet, ok := lookupSubnet(vpcs, aws.ToString(iface.VpcId), aws.ToString(iface.SubnetId)); ok {
+			subnet.ENIs[aws.ToString(iface.NetworkInterfaceId)] = &ifaceIn
+		}
+	}
+}
+
+func newElasticIP(address types.Address) *ElasticIP {
+	pool := aws.ToString(address.PublicIpv4Pool)
+	if address.CustomerOwnedIpv4Pool != nil {
+		pool = aws.ToString(address.CustomerOwnedIpv4Pool)
+	}
+
+	return &ElasticIP{
+		AllocationID:   aws.ToString(address.AllocationId),
+		AssociationID:  aws.ToString(address.AssociationId),
+		PublicIP:       aws.ToString(address.PublicIp),
+		PublicIPv4Pool: pool,
+		Name:           getNameTag(address.Tags),
+		Tags:           getTags(address.Tags),
+		RawAddress:     address,
+	}
+}
+
+// mapAddresses marks the public ips of instances, network interfaces and
+// nat gateways that are elastic ips. This has to happen after network
+// interfaces are mapped, as it looks for them wherever they were placed.
+func mapAddresses(vpcs map[string]*VPC, addresses []types.Address) {
+	byInstance := make(map[string]*ElasticIP)
+	byInterface := make(map[string]*ElasticIP)
+	byAllocation := make(map[string]*ElasticIP)
+
+	for _, address := range addresses {
+		eip := newElasticIP(address)
+		byAllocation[eip.AllocationID] = eip
+
+		if address.InstanceId != nil {
+			byInstance[aws.ToString(address.InstanceId)+"/"+eip.PublicIP] = eip
+		}
+
+		if address.NetworkInterfaceId != nil {
+			byInterface[aws.ToString(address.NetworkInterfaceId)+"/"+eip.PublicIP] = eip
+		}
+	}
+
+	mapInterface := func(iface *NetworkInterface) {
+		iface.ElasticIP = byInterface[iface.ID+"/"+iface.PublicIP]
+	}
+
+	for _, vpc := range vpcs {
+		for _, subnet := range vpc.Subnets {
+			for _, iface := range subnet.ENIs {
+				mapInterface(iface)
+			}
+
+			for _, instance := range subnet.Instances {
+				instance.ElasticIP = byInstance[instance.ID+"/"+instance.PublicIP]
+
+				for _, iface := range instance.Interfaces {
+					mapInterface(iface)
+				}
+			}
+
+			for _, natGateway := range subnet.NatGateways {
+				// The displayed public ip is that of the gateway's first address
+				if addrs := natGateway.RawNatGateway.NatGatewayAddresses; len(addrs) > 0 && addrs[0].AllocationId != nil {
+					natGateway.ElasticIP = byAllocation[aws.ToString(addrs[0].AllocationId)]
+				}
+
+				for _, iface := range natGateway.Interfaces {
+					mapInterface(iface)
+				}
+			}
+
+			for _, endpoint := range subnet.InterfaceEndpoints {
+				for _, iface := range endpoint.Interfaces {
+					mapInterface(iface)
+				}
+			}
+		}
+	}
+}
+
+// unassociatedElasticIPs lists the elastic ips not associated with anything,
+// which are billed while sitting idle.
+func unassociatedElasticIPs(addresses []types.Address) []*ElasticIP {
+	eips := []*ElasticIP{}
+
+	for _, address := range addresses {
+		if address.AssociationId != nil || address.InstanceId != nil || address.NetworkInterfaceId != nil {
+			continue
+		}
+
+		eips = append(eips, newElasticIP(address))
 	}
+
+	return filterElasticIPs(eips)
 }
 
-func extractRules(rules []types.IpPermission) []*SecurityGroupRule {
+func extractRules(rules []types.IpPermission, prefixLists map[string]*PrefixList) []*SecurityGroupRule {
 	rulesOut := []*SecurityGroupRule{}
 
 	for _, rule := range rules {
@@ -475,23 +875,29 @@ func extractRules(rules []types.IpPermission) []*SecurityGroupRule {
 			})
 		}
 
+		rulePrefixLists := []*PrefixList{}
+		for _, prefixListID := range rule.PrefixListIds {
+			rulePrefixLists = append(rulePrefixLists, lookupPrefixList(prefixLists, aws.ToString(prefixListID.PrefixListId)))
+		}
+
 		rulesOut = append(rulesOut, &SecurityGroupRule{
-			FromPort:   aws.ToInt32(rule.FromPort),
-			ToPort:     aws.ToInt32(rule.ToPort),
-			IPProtocol: aws.ToString(rule.IpProtocol),
-			IPRanges:   IPR,
-			IPv6Ranges: IPR6,
-			Groups:     groups,
+			FromPort:    aws.ToInt32(rule.FromPort),
+			ToPort:      aws.ToInt32(rule.ToPort),
+			IPProtocol:  aws.ToString(rule.IpProtocol),
+			IPRanges:    IPR,
+			IPv6Ranges:  IPR6,
+			Groups:      groups,
+			PrefixLists: rulePrefixLists,
 		})
 	}
 
 	return rulesOut
 }
 
-func mapSecurityGroups(vpcs map[string]*VPC, securityGroups []types.SecurityGroup) {
+func mapSecurityGroups(vpcs map[string]*VPC, securityGroups []types.SecurityGroup, prefixLists map[string]*PrefixList) {
 	for _, securityGroup := range securityGroups {
-		InboundRules := extractRules(securityGroup.IpPermissions)
-		OutboundRules := extractRules(securityGroup.IpPermissionsEgress)
+		InboundRules := extractRules(securityGroup.IpPermissions, prefixLists)
+		OutboundRules := extractRules(securityGroup.IpPermissionsEgress, prefixLists)
 
 		securityGroupIn := &SecurityGroup{
 			Description:         aws.ToString(securityGroup.Description),
@@ -573,32 +979,22 @@ func mapSecurityGroups(vpcs map[string]*VPC, securityGroups []types.SecurityGrou
 }
 
 func mapVpcEndpoints(vpcs map[string]*VPC, vpcEndpoints []types.VpcEndpoint) {
-	vpcIDs := dumpVpcIDs(vpcs)
-	subnetIDs := dumpSubnetIDs(vpcs)
-
 	for _, endpoint := range vpcEndpoints {
-		// Validate vpc and subnet values
-		if _, exists := vpcIDs[aws.ToString(endpoint.VpcId)]; !exists {
-			fmt.Printf("Warning: undiscovered VPC %v when processing endpoint %v\n",
-				aws.ToString(endpoint.VpcId),
-				aws.ToString(endpoint.VpcEndpointId),
-			)
-
+		// endpoints aren't filtered by -tag, and vpcs or subnets may have
+		// failed to fetch, so ones outside what was mapped are skipped
+		vpc, ok := vpcs[aws.ToString(endpoint.VpcId)]
+		if !ok {
 			continue
 		}
 
 		if string(endpoint.VpcEndpointType) == "Interface" {
-			for _, subnet := range endpoint.SubnetIds {
-				if _, exists := subnetIDs[subnet]; !exists {
-					fmt.Printf("Warning: undiscovered subnet %v when processing endpoint %v\n",
-						subnet,
-						aws.ToString(endpoint.VpcEndpointId),
-					)
-
+			for _, subnetID := range endpoint.SubnetIds {
+				subnet, ok := vpc.Subnets[subnetID]
+				if !ok {
 					continue
 				}
 
-				vpcs[aws.ToString(endpoint.VpcId)].Subnets[subnet].InterfaceEndpoints[aws.ToString(endpoint.VpcEndpointId)] = &InterfaceEndpoint{
+				subnet.InterfaceEndpoints[aws.ToString(endpoint.VpcEndpointId)] = &InterfaceEndpoint{
 					InterfaceEndpointData: InterfaceEndpointData{
 						ID:          aws.ToString(endpoint.VpcEndpointId),
 						ServiceName: aws.ToString(endpoint.ServiceName),
@@ -612,9 +1008,9 @@ func mapVpcEndpoints(vpcs map[string]*VPC, vpcEndpoints []types.VpcEndpoint) {
 
 		if string(endpoint.VpcEndpointType) == "Gateway" {
 			for _, rtb := range endpoint.RouteTableIds {
-				for _, subnet := range vpcs[aws.ToString(endpoint.VpcId)].Subnets {
-					if subnet.RouteTable.ID == rtb {
-						vpcs[aws.ToString(endpoint.VpcId)].Subnets[subnet.ID].GatewayEndpoints[aws.ToString(endpoint.VpcEndpointId)] = &GatewayEndpoint{
+				for _, subnet := range vpc.Subnets {
+					if subnet.RouteTable != nil && subnet.RouteTable.ID == rtb {
+						subnet.GatewayEndpoints[aws.ToString(endpoint.VpcEndpointId)] = &GatewayEndpoint{
 							ID:          aws.ToString(endpoint.VpcEndpointId),
 							ServiceName: aws.ToString(endpoint.ServiceName),
 							Name:        getNameTag(endpoint.Tags),
@@ -626,25 +1022,3 @@ func mapVpcEndpoints(vpcs map[string]*VPC, vpcEndpoints []types.VpcEndpoint) {
 		}
 	}
 }
-
-func dumpVpcIDs(vpcs map[string]*VPC) map[string]bool {
-	keys := make(map[string]bool)
-
-	for vpcID := range vpcs {
-		keys[vpcID] = true
-	}
-
-	return keys
-}
-
-func dumpSubnetIDs(vpcs map[string]*VPC) map[string]bool {
-	keys := make(map[string]bool)
-
-	for _, vpc := range vpcs {
-		for subnetID := range vpc.Subnets {
-			keys[subnetID] = true
-		}
-	}
-
-	return keys
-}
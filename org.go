@@ -0,0 +1,88 @@
+// Copyright 2026 Stigian Consulting - reference license in top level of project
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// defaultOrgRole is the role Organizations creates in the accounts it creates
+const defaultOrgRole = "OrganizationAccountAccessRole"
+
+// orgAccounts lists the active accounts of the organization, which requires
+// running from the management or a delegated administrator account. The
+// account lsvpc runs in is fetched with the current credentials, the others
+// by assuming -org-role.
+func orgAccounts(ctx context.Context) ([]*AccountData, error) {
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, err
+	}
+
+	callerARN, err := arn.Parse(aws.ToString(identity.Arn))
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := []*AccountData{}
+	paginator := organizations.NewListAccountsPaginator(organizations.NewFromConfig(cfg), &organizations.ListAccountsInput{})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, account := range page.Accounts {
+			// Suspended accounts cannot be accessed, and pending closure is on its way there
+			if account.Status != orgtypes.AccountStatusActive {
+				continue
+			}
+
+			accountData := &AccountData{
+				ID:      aws.ToString(account.Id),
+				Name:    aws.ToString(account.Name),
+				Regions: make(map[string]RegionData),
+			}
+
+			if accountData.ID != aws.ToString(identity.Account) {
+				accountData.RoleARN = fmt.Sprintf(
+					"arn:%v:iam::%v:role/%v",
+					callerARN.Partition,
+					accountData.ID,
+					Config.orgRole,
+				)
+			}
+
+			accounts = append(accounts, accountData)
+		}
+	}
+
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].ID < accounts[j].ID
+	})
+
+	return accounts, nil
+}
+
+func doOrg(ctx context.Context) int {
+	accounts, err := orgAccounts(ctx)
+	if err != nil {
+		fmt.Printf("Could not list organization accounts: %v\n", describeError(err))
+		return 1
+	}
+
+	return doAccounts(ctx, accounts)
+}
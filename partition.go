@@ -0,0 +1,94 @@
+// Copyright 2026 Stigian Consulting - reference license in top level of project
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// partitionRegions maps each partition to the region lsvpc uses when none is
+// configured. Credentials are only accepted within their own partition, so
+// calls made outside of it fail rather than returning another partition's data.
+var partitionRegions = map[string]string{
+	"aws":        "us-east-1",
+	"aws-us-gov": "us-gov-west-1",
+	"aws-cn":     "cn-north-1",
+	"aws-iso":    "us-iso-east-1",
+	"aws-iso-b":  "us-isob-east-1",
+}
+
+// detectOrder is the order partitions are tried in when nothing hints at one
+var detectOrder = []string{"aws", "aws-us-gov", "aws-cn"}
+
+// fallbackRegion is used by every config that has no region of its own. It is
+// set from the detected partition before anything is fetched.
+var fallbackRegion = partitionRegions["aws"]
+
+// regionPartition returns the partition a region belongs to
+func regionPartition(region string) string {
+	switch {
+	case strings.HasPrefix(region, "us-gov-"):
+		return "aws-us-gov"
+	case strings.HasPrefix(region, "cn-"):
+		return "aws-cn"
+	case strings.HasPrefix(region, "us-isob-"):
+		return "aws-iso-b"
+	case strings.HasPrefix(region, "us-iso-"):
+		return "aws-iso"
+	}
+
+	return "aws"
+}
+
+// detectPartition works out which partition the credentials loaded with
+// optFns belong to. -partition wins, then the partition of the configured
+// region. Without either, the caller identity is requested from each
+// partition in turn, and the partition of the returned arn is used, as long
+// as lsvpc knows a region for it.
+func detectPartition(ctx context.Context, optFns ...func(*config.LoadOptions) error) (string, error) {
+	if Config.partition != "" {
+		return Config.partition, nil
+	}
+
+	cfg, err := loadPartitionlessConfig(ctx, optFns...)
+	if err != nil {
+		return "", err
+	}
+
+	if cfg.Region != "" {
+		return regionPartition(cfg.Region), nil
+	}
+
+	errs := []error{}
+
+	for _, partition := range detectOrder {
+		cfg.Region = partitionRegions[partition]
+
+		identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+		if err != nil {
+			errs = append(errs, err)
+
+			continue
+		}
+
+		parsed, err := arn.Parse(aws.ToString(identity.Arn))
+		if err != nil {
+			return "", err
+		}
+
+		if _, ok := partitionRegions[parsed.Partition]; !ok {
+			return "", fmt.Errorf("partition %v of %v is not supported", parsed.Partition, aws.ToString(identity.Arn))
+		}
+
+		return parsed.Partition, nil
+	}
+
+	return "", errors.Join(errs...)
+}
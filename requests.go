@@ -6,15 +6,92 @@ import (
 	"fmt"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/smithy-go/middleware"
+
+	"github.com/stigian/lsvpc/awsfetch"
 )
 
-func getRegions() []string {
-	ctx := context.Background()
-	cfg, err := config.LoadDefaultConfig(ctx)
+// apiLimiter bounds the requests in flight across every client lsvpc builds.
+// It is set up from -max-concurrency before anything is fetched.
+var apiLimiter *awsfetch.Limiter
+
+// loadConfig loads the default sdk config with lsvpc's retry, concurrency,
+// endpoint and partition settings applied. Every client should be built from
+// a config loaded here.
+func loadConfig(ctx context.Context, optFns ...func(*config.LoadOptions) error) (aws.Config, error) {
+	cfg, err := loadPartitionlessConfig(ctx, optFns...)
+	if err != nil {
+		return cfg, err
+	}
+
+	if cfg.Region == "" {
+		cfg.Region = fallbackRegion
+	}
+
+	// A region from outside of -partition would only produce auth failures
+	if Config.partition != "" && regionPartition(cfg.Region) != Config.partition {
+		return cfg, fmt.Errorf("region %v is not in partition %v", cfg.Region, Config.partition)
+	}
+
+	return cfg, nil
+}
+
+// loadPartitionlessConfig is loadConfig without a region filled in when none
+// is configured, for use before the partition is known.
+func loadPartitionlessConfig(ctx context.Context, optFns ...func(*config.LoadOptions) error) (aws.Config, error) {
+	opts := []func(*config.LoadOptions) error{
+		config.WithRetryer(newRetryer),
+	}
+
+	if len(Config.profiles) == 1 {
+		// A single profile stands in for AWS_PROFILE, several are fetched as separate accounts
+		opts = append(opts, config.WithSharedConfigProfile(Config.profiles[0]))
+	}
+
+	if Config.fips {
+		opts = append(opts, config.WithUseFIPSEndpoint(aws.FIPSEndpointStateEnabled))
+	}
+
+	if Config.dualStack {
+		opts = append(opts, config.WithUseDualStackEndpoint(aws.DualStackEndpointStateEnabled))
+	}
+
+	if apiLimiter != nil {
+		opts = append(opts, config.WithAPIOptions([]func(*middleware.Stack) error{
+			apiLimiter.Middleware,
+		}))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, append(opts, optFns...)...)
+	if err != nil {
+		return cfg, err
+	}
+
+	if Config.endpointURL != "" {
+		// Sends both ec2 and sts calls to a stand-in such as LocalStack or moto
+		cfg.BaseEndpoint = aws.String(Config.endpointURL)
+	}
+
+	return cfg, nil
+}
+
+// newRetryer retries failed requests with backoff, and adaptively slows down
+// the rate of requests once AWS starts throttling them.
+func newRetryer() aws.Retryer {
+	return retry.NewAdaptiveMode(func(o *retry.AdaptiveModeOptions) {
+		o.StandardOptions = append(o.StandardOptions, func(so *retry.StandardOptions) {
+			so.MaxAttempts = Config.maxRetries + 1
+		})
+	})
+}
+
+func getRegions(ctx context.Context, optFns ...func(*config.LoadOptions) error) ([]string, error) {
+	cfg, err := loadConfig(ctx, optFns...)
 	if err != nil {
-		panic(fmt.Sprintf("failed to load config: %v", err.Error()))
+		return nil, err
 	}
 
 	svc := ec2.NewFromConfig(cfg)
@@ -22,12 +99,12 @@ func getRegions() []string {
 
 	res, err := svc.DescribeRegions(ctx, &ec2.DescribeRegionsInput{})
 	if err != nil {
-		panic(fmt.Sprintf("Could not get regions: %v", err.Error()))
+		return nil, err
 	}
 
 	for _, region := range res.Regions {
 		regions = append(regions, aws.ToString(region.RegionName))
 	}
 
-	return regions
+	return regions, nil
 }
@@ -0,0 +1,218 @@
+// Copyright 2026 Stigian Consulting - reference license in top level of project
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/stigian/lsvpc/awsfetch"
+)
+
+// Snapshot is the on-disk format written by -save and read by -load. It holds
+// the raw fetch results rather than the mapped model, so that a snapshot can
+// be replayed through the same mappings and display code as a live run.
+type Snapshot struct {
+	Taken          time.Time         `json:"taken"`
+	Regions        []*RegionSnapshot `json:"regions"`
+	FailedAccounts []*FailedAccount  `json:"failedAccounts,omitempty"`
+}
+
+// RegionSnapshot is the raw data of one region. The account fields are only
+// set for regions fetched through -role-arn, -org or several -profile. A
+// region that could not be fetched at all has no data, only its Error.
+type RegionSnapshot struct {
+	Fetch       *awsfetch.AWSFetch `json:"fetch,omitempty"`
+	Account     string             `json:"account,omitempty"`
+	AccountName string             `json:"accountName,omitempty"`
+	RoleARN     string             `json:"roleArn,omitempty"`
+	Profile     string             `json:"profile,omitempty"`
+	Region      string             `json:"region"`
+	Errors      []*FetchError      `json:"errors,omitempty"`
+	Error       string             `json:"error,omitempty"`
+}
+
+// FailedAccount is an account that could not be accessed, kept so that a
+// snapshot tells it apart from an account without any vpcs.
+type FailedAccount struct {
+	Account     string `json:"account,omitempty"`
+	AccountName string `json:"accountName,omitempty"`
+	RoleARN     string `json:"roleArn,omitempty"`
+	Profile     string `json:"profile,omitempty"`
+	Error       string `json:"error"`
+}
+
+func writeSnapshot(path string, snapshot *Snapshot) error {
+	export, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, export, 0o600) //nolint:gomnd // file permissions
+}
+
+func readSnapshot(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &Snapshot{}
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, err
+	}
+
+	for _, region := range snapshot.Regions {
+		if region.Fetch == nil && region.Error == "" {
+			return nil, fmt.Errorf("region %v has no data", region.Region)
+		}
+	}
+
+	return snapshot, nil
+}
+
+// saveRegions writes a snapshot of every region if -save was requested
+func saveRegions(fullData map[string]RegionData) {
+	if Config.savePath == "" {
+		return
+	}
+
+	writeRegionSnapshots(regionSnapshots(&AccountData{Regions: fullData}), nil)
+}
+
+// saveAccounts writes a snapshot of every region of every account if -save
+// was requested. Accounts that could not be accessed are recorded with their error.
+func saveAccounts(accounts []*AccountData) {
+	if Config.savePath == "" {
+		return
+	}
+
+	regions := []*RegionSnapshot{}
+	failed := []*FailedAccount{}
+
+	for _, accountData := range accounts {
+		if accountData.Err != nil {
+			failed = append(failed, &FailedAccount{
+				Account:     accountData.ID,
+				AccountName: accountData.Name,
+				RoleARN:     accountData.RoleARN,
+				Profile:     accountData.Profile,
+				Error:       accountData.Err.Error(),
+			})
+
+			continue
+		}
+
+		regions = append(regions, regionSnapshots(accountData)...)
+	}
+
+	writeRegionSnapshots(regions, failed)
+}
+
+func regionSnapshots(accountData *AccountData) []*RegionSnapshot {
+	fullData := accountData.Regions
+	regionKeys := []string{}
+
+	for k := range fullData {
+		regionKeys = append(regionKeys, k)
+	}
+
+	sort.Strings(regionKeys)
+
+	regions := []*RegionSnapshot{}
+
+	for _, region := range regionKeys {
+		regionSnapshot := &RegionSnapshot{
+			Account:     accountData.ID,
+			AccountName: accountData.Name,
+			RoleARN:     accountData.RoleARN,
+			Profile:     accountData.Profile,
+			Region:      region,
+			Fetch:       fullData[region].Fetch,
+			Errors:      fullData[region].Errors,
+		}
+
+		// Without any data, keep why so the region doesn't read as empty
+		if regionSnapshot.Fetch == nil {
+			regionSnapshot.Error = "no data"
+			if err := fullData[region].Err; err != nil {
+				regionSnapshot.Error = err.Error()
+			}
+		}
+
+		regions = append(regions, regionSnapshot)
+	}
+
+	return regions
+}
+
+func writeRegionSnapshots(regions []*RegionSnapshot, failed []*FailedAccount) {
+	snapshot := &Snapshot{
+		Taken:          time.Now().UTC(),
+		Regions:        regions,
+		FailedAccounts: failed,
+	}
+
+	if err := writeSnapshot(Config.savePath, snapshot); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to save snapshot: %v\n", err)
+	}
+}
+
+// snapshotAccounts rebuilds the accounts and regions held in a snapshot,
+// keeping only the -r region if one was given.
+func snapshotAccounts(snapshot *Snapshot) []*AccountData {
+	accounts := []*AccountData{}
+	accountIdx := make(map[string]*AccountData)
+
+	for _, region := range snapshot.Regions {
+		if Config.regionOverride != "" && region.Region != Config.regionOverride {
+			continue
+		}
+
+		// Several profiles may lead into the same account
+		accountKey := region.Profile + "/" + region.Account
+
+		accountData, ok := accountIdx[accountKey]
+		if !ok {
+			accountData = &AccountData{
+				ID:      region.Account,
+				Name:    region.AccountName,
+				RoleARN: region.RoleARN,
+				Profile: region.Profile,
+				Regions: make(map[string]RegionData),
+			}
+			accountIdx[accountKey] = accountData
+			accounts = append(accounts, accountData)
+		}
+
+		if region.Fetch == nil {
+			accountData.Regions[region.Region] = RegionData{Err: errors.New(region.Error)}
+
+			continue
+		}
+
+		accountData.Regions[region.Region] = RegionData{
+			VPCs:       populateVPC(region.Fetch),
+			ElasticIPs: unassociatedElasticIPs(awsfetch.Addresses.From(region.Fetch).Data),
+			Fetch:      region.Fetch,
+			Errors:     region.Errors,
+			Err:        regionFailure(region.Errors),
+		}
+	}
+
+	for _, failed := range snapshot.FailedAccounts {
+		accounts = append(accounts, &AccountData{
+			ID:      failed.Account,
+			Name:    failed.AccountName,
+			RoleARN: failed.RoleARN,
+			Profile: failed.Profile,
+			Regions: make(map[string]RegionData),
+			Err:     errors.New(failed.Error),
+		})
+	}
+
+	return accounts
+}
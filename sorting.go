@@ -9,6 +9,29 @@ func asyncSortRegion(vpcs map[string]*VPC, out chan []*VPCSorted) {
 	out <- sortVPCs(vpcs)
 }
 
+// sortAccountData keeps accounts in the order they were given
+func sortAccountData(accounts []*AccountData) []*AccountDataSorted {
+	accountsSorted := []*AccountDataSorted{}
+
+	for _, accountData := range accounts {
+		accountSorted := &AccountDataSorted{
+			Account: accountData.ID,
+			Name:    accountData.Name,
+			RoleARN: accountData.RoleARN,
+			Profile: accountData.Profile,
+			Regions: sortRegionData(accountData.Regions),
+		}
+
+		if accountData.Err != nil {
+			accountSorted.Error = accountData.Err.Error()
+		}
+
+		accountsSorted = append(accountsSorted, accountSorted)
+	}
+
+	return accountsSorted
+}
+
 func sortRegionData(regionData map[string]RegionData) []*RegionDataSorted {
 	regionKeys := []string{}
 
@@ -31,8 +54,14 @@ func sortRegionData(regionData map[string]RegionData) []*RegionDataSorted {
 	regionDataIn := make(map[string]*RegionDataSorted)
 	for _, region := range regionKeys {
 		regionDataIn[region] = &RegionDataSorted{
-			VPCs:   <-regionDataInterstitial[region],
-			Region: region,
+			VPCs:       <-regionDataInterstitial[region],
+			ElasticIPs: sortElasticIPs(regionData[region].ElasticIPs),
+			Region:     region,
+			Errors:     regionData[region].Errors,
+		}
+
+		if err := regionData[region].Err; err != nil {
+			regionDataIn[region].Error = err.Error()
 		}
 	}
 
@@ -280,3 +309,13 @@ func sortNatGateway(ng *NatGateway) *NatGatewaySorted {
 		Interfaces:     ifaceSorted,
 	}
 }
+
+func sortElasticIPs(eips []*ElasticIP) []*ElasticIP {
+	eipsSorted := append([]*ElasticIP{}, eips...)
+
+	sort.Slice(eipsSorted, func(i, j int) bool {
+		return eipsSorted[i].AllocationID < eipsSorted[j].AllocationID
+	})
+
+	return eipsSorted
+}
@@ -0,0 +1,332 @@
+// Copyright 2026 Stigian Consulting - reference license in top level of project
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/stigian/lsvpc/awsfetch"
+)
+
+const clearScreen = "\033[H\033[2J"
+
+// watchItem is a displayed resource as seen by one refresh of -watch
+type watchItem struct {
+	kind    string
+	label   string
+	state   string   // lifecycle state, for the resources that have one
+	detail  string   // everything else displayed about it
+	region  string   // region it was seen in
+	sources []string // the resources it is built from, its parents' included
+}
+
+// watchChanges marks the resources that appeared or changed since the
+// previous refresh of -watch, by id. It is nil outside of -watch.
+var watchChanges map[string]string
+
+// changeMark returns the marker displayed after a resource that appeared or
+// changed since the previous refresh
+func changeMark(id string) string {
+	return watchChanges[id]
+}
+
+// inventory flattens the displayed resources of every region, keyed by id
+func inventory(fullData map[string]RegionData) map[string]watchItem {
+	items := make(map[string]watchItem)
+
+	for region, regionData := range fullData {
+		vpcSources := []string{awsfetch.Vpcs.Name, awsfetch.InternetGateways.Name, awsfetch.EgressOnlyInternetGateways.Name, awsfetch.VPNGateways.Name}
+
+		for _, vpc := range regionData.VPCs {
+			items[vpc.ID] = watchItem{
+				kind:    "vpc",
+				label:   vpc.ID + formatName(vpc.Name),
+				detail:  fmt.Sprint(vpc.CidrBlock, vpc.IPv6CidrBlock, vpc.Gateways),
+				region:  region,
+				sources: vpcSources,
+			}
+
+			for _, peer := range vpc.Peers {
+				items[peer.ID] = watchItem{
+					kind:    "peering connection",
+					label:   peer.ID + formatName(peer.Name),
+					detail:  fmt.Sprint(peer.Requester, peer.Accepter),
+					region:  region,
+					sources: []string{awsfetch.Vpcs.Name, awsfetch.PeeringConnections.Name},
+				}
+			}
+
+			for _, subnet := range vpc.Subnets {
+				subnetInventory(items, region, subnet)
+			}
+		}
+	}
+
+	return items
+}
+
+func subnetInventory(items map[string]watchItem, region string, subnet *Subnet) {
+	defaultRoute := ""
+	if subnet.RouteTable != nil {
+		defaultRoute = subnet.RouteTable.Default
+	}
+
+	// from returns the sources of something within the subnet
+	from := func(resources ...string) []string {
+		return append([]string{awsfetch.Vpcs.Name, awsfetch.Subnets.Name}, resources...)
+	}
+
+	items[subnet.ID] = watchItem{
+		kind:    "subnet",
+		label:   subnet.ID + formatName(subnet.Name),
+		detail:  fmt.Sprint(subnet.CidrBlock, defaultRoute, subnet.Class),
+		region:  region,
+		sources: from(awsfetch.RouteTables.Name, awsfetch.PrefixLists.Name),
+	}
+
+	for _, instance := range subnet.Instances {
+		items[instance.ID] = watchItem{
+			kind:  "instance",
+			label: instance.ID + formatName(instance.Name),
+			state: instance.State,
+			detail: fmt.Sprint(
+				instance.Type,
+				instance.PublicIP,
+				instance.PrivateIP,
+				instance.InstanceStatus,
+				instance.SystemStatus,
+			),
+			region:  region,
+			sources: from(awsfetch.Instances.Name, awsfetch.InstanceStatuses.Name),
+		}
+	}
+
+	for _, natGateway := range subnet.NatGateways {
+		items[natGateway.ID] = watchItem{
+			kind:    "nat gateway",
+			label:   natGateway.ID + formatName(natGateway.Name),
+			state:   natGateway.State,
+			detail:  fmt.Sprint(natGateway.PublicIP, natGateway.PrivateIP),
+			region:  region,
+			sources: from(awsfetch.NatGateways.Name),
+		}
+	}
+
+	for _, tgw := range subnet.TGWs {
+		items[tgw.AttachmentID] = watchItem{
+			kind:    "transit gateway attachment",
+			label:   tgw.AttachmentID + formatName(tgw.Name),
+			detail:  tgw.TransitGatewayID,
+			region:  region,
+			sources: from(awsfetch.TransitGatewayAttachments.Name),
+		}
+	}
+
+	for _, iface := range subnet.ENIs {
+		items[iface.ID] = watchItem{
+			kind:    "network interface",
+			label:   iface.ID + formatName(iface.Name),
+			detail:  fmt.Sprint(iface.PublicIP, iface.PrivateIP, iface.Description),
+			region:  region,
+			sources: from(awsfetch.NetworkInterfaces.Name),
+		}
+	}
+
+	for _, endpoint := range subnet.InterfaceEndpoints {
+		items[endpoint.ID] = watchItem{
+			kind:    "interface endpoint",
+			label:   endpoint.ID + formatName(endpoint.Name),
+			detail:  endpoint.ServiceName,
+			region:  region,
+			sources: from(awsfetch.VPCEndpoints.Name),
+		}
+	}
+
+	for _, endpoint := range subnet.GatewayEndpoints {
+		items[endpoint.ID] = watchItem{
+			kind:    "gateway endpoint",
+			label:   endpoint.ID + formatName(endpoint.Name),
+			detail:  endpoint.ServiceName,
+			region:  region,
+			sources: from(awsfetch.VPCEndpoints.Name, awsfetch.RouteTables.Name),
+		}
+	}
+}
+
+// carryForward keeps what the previous refresh saw of everything the
+// current one could not fetch, because its region failed or one of the
+// resources it is built from did. Without it these would be reported gone,
+// and then new again once fetching recovers.
+func carryForward(previous, current map[string]watchItem, fullData map[string]RegionData) {
+	failed := func(item watchItem) bool {
+		regionData, ok := fullData[item.region]
+		if !ok || regionData.Err != nil {
+			return true
+		}
+
+		for _, fetchErr := range regionData.Errors {
+			for _, source := range item.sources {
+				if fetchErr.Resource == source {
+					return true
+				}
+			}
+		}
+
+		return false
+	}
+
+	for id, item := range previous {
+		if failed(item) {
+			current[id] = item
+		}
+	}
+}
+
+// diffInventory marks what appeared or changed between two refreshes, and
+// lists what disappeared. Nothing is marked on the first refresh.
+func diffInventory(previous, current map[string]watchItem) (map[string]string, []watchItem) {
+	changes := make(map[string]string)
+	gone := []watchItem{}
+
+	if previous == nil {
+		return changes, gone
+	}
+
+	for id, item := range current {
+		before, ok := previous[id]
+
+		switch {
+		case !ok:
+			changes[id] = fmt.Sprintf(" %v(new)%v", color.Green, color.Reset)
+		case before.state != item.state:
+			changes[id] = fmt.Sprintf(" %v(was %v)%v", color.Yellow, before.state, color.Reset)
+		case before.detail != item.detail:
+			changes[id] = fmt.Sprintf(" %v(changed)%v", color.Yellow, color.Reset)
+		}
+	}
+
+	for id, item := range previous {
+		if _, ok := current[id]; !ok {
+			gone = append(gone, item)
+		}
+	}
+
+	sort.Slice(gone, func(i, j int) bool {
+		return gone[i].label < gone[j].label
+	})
+
+	return changes, gone
+}
+
+func printGone(gone []watchItem) {
+	if len(gone) == 0 {
+		return
+	}
+
+	fmt.Printf("%vGone since the last refresh:%v\n", color.Red, color.Reset)
+
+	for _, item := range gone {
+		fmt.Printf("%s%v%v %v%v\n", indent(4), color.Red, item.kind, item.label, color.Reset) //nolint:gomnd // not a magic number, spaces to indent by
+	}
+
+	lineFeed()
+}
+
+// refreshRegions fetches the regions for one refresh of -watch, with -timeout
+// applying to each refresh rather than to the whole run.
+func refreshRegions(ctx context.Context) (map[string]RegionData, error) {
+	if Config.timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, Config.timeout)
+		defer cancel()
+	}
+
+	regions, err := accountRegions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return fetchRegions(ctx, regions), nil
+}
+
+// doWatch redraws the listing every -watch interval until interrupted,
+// marking what appeared, changed or disappeared since the previous refresh.
+func doWatch(ctx context.Context) {
+	var previous map[string]watchItem
+
+	ticker := time.NewTicker(Config.watch)
+	defer ticker.Stop()
+
+	for {
+		fullData, err := refreshRegions(ctx)
+		if ctx.Err() != nil {
+			// Interrupted, leave the last complete refresh on screen
+			return
+		}
+
+		fmt.Print(clearScreen)
+		fmt.Printf("Every %v, last refreshed %v\n\n", Config.watch, time.Now().Format(time.TimeOnly))
+
+		if err != nil {
+			fmt.Printf("%vCould not get regions: %v%v\n", color.Red, describeError(err), color.Reset)
+		} else {
+			current := inventory(fullData)
+			carryForward(previous, current, fullData)
+
+			var gone []watchItem
+
+			watchChanges, gone = diffInventory(previous, current)
+			previous = current
+
+			printWatched(fullData)
+			printGone(gone)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// printWatched prints a refresh the same way a single run would
+func printWatched(fullData map[string]RegionData) {
+	if Config.allRegions {
+		printRegions(fullData)
+
+		return
+	}
+
+	for region, regionData := range fullData {
+		printRegion(region, regionData)
+	}
+}
+
+// watchConflicts lists the flags given alongside -watch that it cannot be combined with
+func watchConflicts(roles []string) string {
+	conflicts := []string{}
+
+	for flagName, set := range map[string]bool{
+		"-j":                       Config.jsonOutput,
+		"-save":                    Config.savePath != "",
+		"-load":                    Config.loadPath != "",
+		"-diff-against":            Config.diffAgainst != "",
+		"-stream":                  Config.stream,
+		"-org":                     Config.org,
+		"-role-arn/-accounts-file": len(roles) > 0,
+		"several -profile":         len(Config.profiles) > 1,
+	} {
+		if set {
+			conflicts = append(conflicts, flagName)
+		}
+	}
+
+	sort.Strings(conflicts)
+
+	return strings.Join(conflicts, ", ")
+}